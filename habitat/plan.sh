@@ -51,8 +51,8 @@ do_check() {
   PATH="${PATH}:$(go env GOPATH)/bin" golangci-lint run
 
   # Perform unit tests
-  build_line "Running go unit tests for vault..."
-  go test -race github.com/Indellient/vault-helper/pkg/vault
+  build_line "Running go unit tests for vault, logger and cli..."
+  go test -race github.com/Indellient/vault-helper/pkg/vault github.com/Indellient/vault-helper/pkg/logger github.com/Indellient/vault-helper/pkg/cli
 }
 
 do_install() {
@@ -25,10 +25,13 @@ var (
 func main() {
 	// Start up our context var, which we pass down to other pkgs
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 
 	// Parse the cli arguments, and perform the action(s)
 	cli.BuildVersion = BuildVersion
 	cli.BuildTimestamp = BuildTimestamp
-	cli.Run(ctx, os.Args)
+	exitCode := cli.Run(ctx, os.Args)
+
+	// os.Exit does not run deferred calls, so cancel the context ourselves first
+	cancel()
+	os.Exit(exitCode)
 }
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+// Set once STDIN was read for a credential, so a second '-' fails instead of silently reading nothing
+var stdinRead bool
+
+// Looks up a credential from its environment variable, its flag, or the file given with its '-file' flag, in that
+// order. The flag can also be '@path' to read the credential from a file, or '-' to read it from STDIN, so the
+// credential never shows up in the process list or the environment of the process.
+func getCredential(environmentKey, value, file string) (string, error) {
+	value = GetEnvValue(environmentKey, value)
+
+	switch {
+	case value == "-":
+		return readCredentialStdin()
+	case strings.HasPrefix(value, "@"):
+		return readCredentialFile(strings.TrimPrefix(value, "@"))
+	case value == "" && file != "":
+		return readCredentialFile(file)
+	default:
+		return value, nil
+	}
+}
+
+// Reads a credential from a file, trimming the trailing newline most editors and 'echo' leave behind
+func readCredentialFile(file string) (string, error) {
+	if file == "-" {
+		return readCredentialStdin()
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", &vault.ValidationError{Err: fmt.Errorf("Could not read credential file '%v': %w", file, err)}
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+func readCredentialStdin() (string, error) {
+	content, err := readStdin("credential")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Reads all of STDIN, for the one credential, value or input given as '-'. What is read is named in the errors.
+func readStdin(what string) ([]byte, error) {
+	if stdinRead {
+		return nil, &vault.ValidationError{Err: fmt.Errorf("Could not read %v from STDIN, only one credential, value or input can be read from STDIN", what)}
+	}
+	stdinRead = true
+
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, &vault.ValidationError{Err: fmt.Errorf("Could not read %v from STDIN: %w", what, err)}
+	}
+
+	return content, nil
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestGetCredential(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "secret-id")
+	assert.Nil(t, os.WriteFile(file, []byte("ea7-beef\n"), 0600))
+
+	// Whatever is in the environment of the test is put back once we are done
+	defer func(secretId string) { _ = os.Setenv(EnvVaultSecretId, secretId) }(os.Getenv(EnvVaultSecretId))
+	assert.Nil(t, os.Setenv(EnvVaultSecretId, ""))
+
+	// Nothing given
+	credential, err := getCredential(EnvVaultSecretId, "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "", credential)
+
+	// Value given
+	credential, err = getCredential(EnvVaultSecretId, "dead-beef", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "dead-beef", credential)
+
+	// Value given as @file, trimmed
+	credential, err = getCredential(EnvVaultSecretId, "@"+file, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "ea7-beef", credential)
+
+	// File given, trimmed
+	credential, err = getCredential(EnvVaultSecretId, "", file)
+	assert.Nil(t, err)
+	assert.Equal(t, "ea7-beef", credential)
+
+	// Value wins over the file, which is not read at all
+	credential, err = getCredential(EnvVaultSecretId, "dead-beef", path.Join(dir, "missing"))
+	assert.Nil(t, err)
+	assert.Equal(t, "dead-beef", credential)
+
+	// Environment variable wins over the value and the file
+	assert.Nil(t, os.Setenv(EnvVaultSecretId, "f00d-beef"))
+
+	credential, err = getCredential(EnvVaultSecretId, "dead-beef", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "f00d-beef", credential)
+
+	credential, err = getCredential(EnvVaultSecretId, "", file)
+	assert.Nil(t, err)
+	assert.Equal(t, "f00d-beef", credential)
+
+	// Environment variable given as @file
+	assert.Nil(t, os.Setenv(EnvVaultSecretId, "@"+file))
+
+	credential, err = getCredential(EnvVaultSecretId, "dead-beef", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "ea7-beef", credential)
+
+	// A file that cannot be read is bad input
+	var validationError *vault.ValidationError
+	assert.Nil(t, os.Setenv(EnvVaultSecretId, ""))
+
+	_, err = getCredential(EnvVaultSecretId, "@"+path.Join(dir, "missing"), "")
+	assert.True(t, errors.As(err, &validationError), "Expected getCredential() to return a ValidationError for a missing file, got: %v", err)
+}
+
+func TestGetCredentialStdin(t *testing.T) {
+	stdin := path.Join(t.TempDir(), "stdin")
+	assert.Nil(t, os.WriteFile(stdin, []byte("ea7-beef\n"), 0600))
+
+	// Whatever is in the environment and on STDIN of the test is put back once we are done
+	defer func(roleId, secretId string) {
+		_ = os.Setenv(EnvVaultRoleId, roleId)
+		_ = os.Setenv(EnvVaultSecretId, secretId)
+	}(os.Getenv(EnvVaultRoleId), os.Getenv(EnvVaultSecretId))
+	assert.Nil(t, os.Setenv(EnvVaultRoleId, ""))
+	assert.Nil(t, os.Setenv(EnvVaultSecretId, ""))
+
+	defer func(file *os.File) { os.Stdin, stdinRead = file, false }(os.Stdin)
+	file, err := os.Open(stdin)
+	assert.Nil(t, err)
+	defer file.Close()
+	os.Stdin, stdinRead = file, false
+
+	// '-' reads STDIN as a file, trimmed like one
+	credential, err := getCredential(EnvVaultSecretId, "", "-")
+	assert.Nil(t, err)
+	assert.Equal(t, "ea7-beef", credential)
+
+	// STDIN can only be read once
+	_, err = getCredential(EnvVaultRoleId, "-", "")
+	assert.NotNil(t, err, "Expected getCredential() to return error for reading STDIN twice")
+}
+
+func TestLoginFlags_GetSecretIdFile(t *testing.T) {
+	// Our flags
+	var secretId, secretIdFile, wrapped string
+	flags := &loginFlags{secretId: &secretId, secretIdFile: &secretIdFile, wrapped: &wrapped}
+
+	// Whatever is in the environment of the test is put back once we are done
+	defer func(secretId, wrapped string) {
+		_ = os.Setenv(EnvVaultSecretId, secretId)
+		_ = os.Setenv(EnvVaultWrappedSecretId, wrapped)
+	}(os.Getenv(EnvVaultSecretId), os.Getenv(EnvVaultWrappedSecretId))
+	assert.Nil(t, os.Setenv(EnvVaultSecretId, ""))
+	assert.Nil(t, os.Setenv(EnvVaultWrappedSecretId, ""))
+
+	// Nothing given
+	assert.Equal(t, "", flags.getSecretIdFile())
+
+	// Secret id given as @file
+	secretId = "@/etc/vault/secret-id"
+	assert.Equal(t, "/etc/vault/secret-id", flags.getSecretIdFile())
+
+	// Secret id given as @file wins over the wrapped secret id
+	wrapped = "@/etc/vault/wrapped"
+	assert.Equal(t, "/etc/vault/secret-id", flags.getSecretIdFile())
+
+	// Environment variable given as @file wins over the secret id
+	assert.Nil(t, os.Setenv(EnvVaultSecretId, "@/etc/vault/env-secret-id"))
+	assert.Equal(t, "/etc/vault/env-secret-id", flags.getSecretIdFile())
+	assert.Nil(t, os.Setenv(EnvVaultSecretId, ""))
+
+	// Wrapped secret id given as @file
+	secretId = ""
+	assert.Equal(t, "/etc/vault/wrapped", flags.getSecretIdFile())
+
+	// Secret id file given
+	wrapped, secretIdFile = "", "/etc/vault/secret-id"
+	assert.Equal(t, "/etc/vault/secret-id", flags.getSecretIdFile())
+
+	// Secret id given as a value, so the file is not read
+	secretId = "ea7-beef"
+	assert.Equal(t, "", flags.getSecretIdFile())
+
+	// STDIN is never removed
+	secretId, secretIdFile = "", "-"
+	assert.Equal(t, "", flags.getSecretIdFile())
+}
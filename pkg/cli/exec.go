@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+)
+
+var (
+	// The environment variables we log in with, which the command is not handed along with its secrets
+	loginEnvironment = []string{EnvVaultRoleId, EnvVaultSecretId, EnvVaultWrappedSecretId, EnvVaultToken}
+)
+
+// Returned when the child process of 'exec' exits non-zero, so we exit with the same status
+type ChildExitError struct {
+	Code int
+}
+
+func (i *ChildExitError) Error() string {
+	return fmt.Sprintf("Command exited with status %v", i.Code)
+}
+
+// Runs the command with the extra environment variables on top of our own, forwarding signals we receive to it until it
+// exits.
+func runChild(args []string, environment []string) error {
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = childEnvironment(os.Environ(), environment)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	// Start listening before the child starts, so no signal slips through in between
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	err := child.Start()
+	if err != nil {
+		return fmt.Errorf("Could not start command '%v': %w", args[0], err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = child.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = child.Wait()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return &ChildExitError{Code: exitStatus(child.ProcessState)}
+		}
+
+		return fmt.Errorf("Could not wait for command '%v': %w", args[0], err)
+	}
+
+	return nil
+}
+
+// Our own environment without the credentials we logged in with, followed by the extra environment variables. Secrets
+// with the same name as a credential, like a VAULT_TOKEN key, are still passed on.
+func childEnvironment(environ []string, environment []string) []string {
+	inherited := make([]string, 0, len(environ)+len(environment))
+
+	for _, variable := range environ {
+		if !isLoginVariable(strings.SplitN(variable, "=", 2)[0]) {
+			inherited = append(inherited, variable)
+		}
+	}
+
+	return append(inherited, environment...)
+}
+
+func isLoginVariable(name string) bool {
+	for _, login := range loginEnvironment {
+		// Environment variable names are case-insensitive on windows
+		if name == login || (runtime.GOOS == "windows" && strings.EqualFold(name, login)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChildEnvironment(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin:/bin",
+		"VAULT_ADDR=https://somewhere:8200",
+		"VAULT_ROLE_ID=dead-beef",
+		"VAULT_SECRET_ID=ea7-beef",
+		"VAULT_WRAPPED_SECRET_ID=ea7-f00d",
+		"VAULT_TOKEN=dead-c0de",
+		"VAULT_NAMESPACE=team-a",
+	}
+
+	// The credentials we logged in with are not handed to the command
+	environment := childEnvironment(environ, []string{"DB_USERNAME=admin", "DB_PASSWORD=bacon"})
+	assert.Equal(t, []string{
+		"PATH=/usr/bin:/bin",
+		"VAULT_ADDR=https://somewhere:8200",
+		"VAULT_NAMESPACE=team-a",
+		"DB_USERNAME=admin",
+		"DB_PASSWORD=bacon",
+	}, environment)
+
+	// Unless they are one of its secrets
+	environment = childEnvironment(environ, []string{"VAULT_TOKEN=f00d-c0de"})
+	assert.Equal(t, []string{
+		"PATH=/usr/bin:/bin",
+		"VAULT_ADDR=https://somewhere:8200",
+		"VAULT_NAMESPACE=team-a",
+		"VAULT_TOKEN=f00d-c0de",
+	}, environment)
+
+	// Variables without a value are dropped the same way
+	environment = childEnvironment([]string{"VAULT_TOKEN", "HOME=/root"}, nil)
+	assert.Equal(t, []string{"HOME=/root"}, environment)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package cli
+
+import (
+	"os"
+)
+
+var (
+	forwardedSignals = []os.Signal{os.Interrupt}
+)
+
+func exitStatus(state *os.ProcessState) int {
+	return state.ExitCode()
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strings"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+// The flags shared by every command that logs in to vault to create a token
+type loginFlags struct {
+	method       *string
+	mount        *string
+	roleId       *string
+	roleIdFile   *string
+	secretId     *string
+	secretIdFile *string
+	wrapped      *string
+	removeFile   *bool
+	role         *string
+	jwtPath      *string
+}
+
+func newLoginFlags(cmd *kingpin.CmdClause) *loginFlags {
+	return newLoginFlagsWithRole(cmd, "role")
+}
+
+// Same as newLoginFlags, for commands that have a --role flag of their own, like the PKI role of 'pki issue'
+func newLoginFlagsWithRole(cmd *kingpin.CmdClause, roleFlag string) *loginFlags {
+	return &loginFlags{
+		method:       cmd.Flag("method", "The auth method used to log in, one of: approle, kubernetes, cert").Default(vault.AuthMethodApprole).Enum(vault.AuthMethods...),
+		mount:        cmd.Flag("auth-mount", "The path the auth method is mounted at, like 'approle-ci'. Defaults to the name of --method.").String(),
+		roleId:       cmd.Flag("role-id", "The Vault Approle Role Id, '@file' to read it from a file or '-' from STDIN (VAULT_ROLE_ID)").String(),
+		roleIdFile:   cmd.Flag("role-id-file", "A file to read the Vault Approle Role Id from, or '-' for STDIN").String(),
+		secretId:     cmd.Flag("secret-id", "The Vault Approle Secret Id, '@file' to read it from a file or '-' from STDIN (VAULT_SECRET_ID)").String(),
+		secretIdFile: cmd.Flag("secret-id-file", "A file to read the Vault Approle Secret Id from, or '-' for STDIN").String(),
+		wrapped:      cmd.Flag("secret-id-wrapped", "A wrapping token for the Vault Approle Secret Id, unwrapped before logging in. Also takes '@file' or '-' (VAULT_WRAPPED_SECRET_ID)").String(),
+		removeFile:   cmd.Flag("remove-secret-id-file", "Remove the file the (wrapped) secret id was read from after logging in successfully.").Bool(),
+		role:         cmd.Flag(roleFlag, "The Vault Kubernetes auth role with --method=kubernetes, or the optional cert role with --method=cert").String(),
+		jwtPath:      cmd.Flag("jwt-path", "The Kubernetes service account token, used with --method=kubernetes").Default(vault.DefaultKubernetesJWTPath).String(),
+	}
+}
+
+// Sets the auth method and its inputs on the client, ahead of it logging in
+func (f *loginFlags) apply(client *vault.Client) error {
+	wrapped, err := getCredential(EnvVaultWrappedSecretId, *f.wrapped, "")
+	if err != nil {
+		return err
+	}
+
+	client.AuthMethod = *f.method
+	client.AuthMount = *f.mount
+	client.WrappedSecretId = wrapped
+	client.Role = *f.role
+	client.JWTPath = *f.jwtPath
+
+	if *f.removeFile {
+		client.RemoveSecretIdFile = f.getSecretIdFile()
+	}
+
+	return nil
+}
+
+// Applies the flags to the client and returns the role id and secret id it logs in with
+func (f *loginFlags) credentials(client *vault.Client) (string, string, error) {
+	err := f.apply(client)
+	if err != nil {
+		return "", "", err
+	}
+
+	roleId, err := f.getRoleId()
+	if err != nil {
+		return "", "", err
+	}
+
+	secretId, err := f.getSecretId()
+	if err != nil {
+		return "", "", err
+	}
+
+	return roleId, secretId, nil
+}
+
+func (f *loginFlags) getRoleId() (string, error) {
+	return getCredential(EnvVaultRoleId, *f.roleId, *f.roleIdFile)
+}
+
+func (f *loginFlags) getSecretId() (string, error) {
+	return getCredential(EnvVaultSecretId, *f.secretId, *f.secretIdFile)
+}
+
+// The file the secret id (or the wrapped secret id) is read from, if any. STDIN is never removed.
+func (f *loginFlags) getSecretIdFile() string {
+	secretId := GetEnvValue(EnvVaultSecretId, *f.secretId)
+	wrapped := GetEnvValue(EnvVaultWrappedSecretId, *f.wrapped)
+
+	switch {
+	case strings.HasPrefix(secretId, "@"):
+		return strings.TrimPrefix(secretId, "@")
+	case strings.HasPrefix(wrapped, "@"):
+		return strings.TrimPrefix(wrapped, "@")
+	case secretId == "" && *f.secretIdFile != "-":
+		return *f.secretIdFile
+	default:
+		return ""
+	}
+}
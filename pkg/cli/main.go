@@ -2,22 +2,47 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"os"
+	"os/signal"
 	path "path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/Indellient/vault-helper/pkg/logger"
 	"github.com/Indellient/vault-helper/pkg/vault"
 )
 
 const (
-	EnvVaultAddr     = "VAULT_ADDR"
-	EnvVaultInsecure = "VAULT_SKIP_VERIFY"
-	EnvVaultRoleId   = "VAULT_ROLE_ID"
-	EnvVaultSecretId = "VAULT_SECRET_ID"
-	EnvVaultToken    = "VAULT_TOKEN"
+	EnvVaultAddr            = "VAULT_ADDR"
+	EnvVaultInsecure        = "VAULT_SKIP_VERIFY"
+	EnvVaultRoleId          = "VAULT_ROLE_ID"
+	EnvVaultSecretId        = "VAULT_SECRET_ID"
+	EnvVaultWrappedSecretId = "VAULT_WRAPPED_SECRET_ID"
+	EnvVaultToken           = "VAULT_TOKEN"
+
+	EnvVaultNamespace     = "VAULT_NAMESPACE"
+	EnvVaultAuthNamespace = "VAULT_AUTH_NAMESPACE"
+
+	EnvVaultCACert        = "VAULT_CACERT"
+	EnvVaultCAPath        = "VAULT_CAPATH"
+	EnvVaultClientCert    = "VAULT_CLIENT_CERT"
+	EnvVaultClientKey     = "VAULT_CLIENT_KEY"
+	EnvVaultTLSServerName = "VAULT_TLS_SERVER_NAME"
+)
+
+// Exit status codes returned by Run, one per class of error returned from the vault pkg
+const (
+	ExitCodeOK         = 0
+	ExitCodeError      = 1
+	ExitCodeValidation = 2
+	ExitCodeHTTP       = 3
+	ExitCodeResponse   = 4
+	ExitCodeTemplate   = 5
+	ExitCodeCommand    = 6
 )
 
 var (
@@ -30,17 +55,41 @@ var (
 	app = kingpin.New(filename, fmt.Sprintf(`Description:
 	A command-line vault secrets fetcher and template parser.
 
-	When invoking with 'parse', a token is generated, used, and automatically revoked.
+	When invoking with 'parse', a token is generated, used, and automatically revoked. With 'parse --watch', the token is
+	kept alive for as long as vault-helper runs, and revoked when it is interrupted.
 
-	If a token is created or renewed, it must be revoked manually with 'revoke'.
+	If a token is created or renewed, it must be revoked manually with 'revoke'. Commands taking a --token fall back to the
+	token stored with 'login' in ~/.vault-token, or to the token_helper configured in ~/.vault, like the vault CLI.
 
-	Vault environment variables VAULT_ADDR, VAULT_SKIP_VERIFY, VAULT_ROLE_ID, VAULT_SECRET_ID, VAULT_TOKEN override command
-	line options.
+	Vault environment variables VAULT_ADDR, VAULT_SKIP_VERIFY, VAULT_CACERT, VAULT_CAPATH, VAULT_CLIENT_CERT,
+	VAULT_CLIENT_KEY, VAULT_TLS_SERVER_NAME, VAULT_NAMESPACE, VAULT_AUTH_NAMESPACE, VAULT_ROLE_ID, VAULT_SECRET_ID,
+	VAULT_WRAPPED_SECRET_ID, VAULT_TOKEN override command line options.
 
 Usage:
 	Generate a new approle token:
 		%v token create --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef"
 
+	Generate a new approle token from a response-wrapped secret id, which can only be unwrapped once:
+		%v token create --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id-wrapped="s.wrap-beef"
+
+	Generate a new token from inside a kubernetes pod, using its service account token:
+		%v token create --addr="http://somewhere:8200" --method=kubernetes --role="jenkins"
+
+	Generate a new token with the machine's client certificate:
+		%v token create --addr="https://somewhere:8200" --method=cert --client-cert="host.pem" --client-key="host-key.pem"
+
+	Generate a new token with an approle in a parent namespace, for use in a child namespace:
+		%v token create --addr="https://somewhere:8200" --namespace="team-a/app" --auth-namespace="team-a" --role-id="dead-beef" --secret-id="ea7-beef"
+
+	Generate a new token with an approle mounted at a custom path, like auth/approle-ci:
+		%v token create --addr="http://somewhere:8200" --auth-mount="approle-ci" --role-id="dead-beef" --secret-id="ea7-beef"
+
+	Generate a new approle token reading the secret id from a file, which is removed once it was used to log in:
+		%v token create --addr="http://somewhere:8200" --role-id="@/etc/vault/role-id" --secret-id-file="/etc/vault/secret-id" --remove-secret-id-file
+
+	Log in and store the token for the commands below, which use it when no --token is given:
+		%v login --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef"
+
 	Renew an existing token (non-zero exit if the token cannot be renewed):
 		%v token renew --addr="http://somewhere:8200" --token="dead-c0de"
 
@@ -48,79 +97,662 @@ Usage:
 		%v token revoke --addr="http://somewhere:8200" --token="dead-c0de"
 
 	Fetch a secret:
-		%v secret --addr="http://somewhere:8200" --token="dead-c0de" --path="secret/data/jenkins/dev/user/admin" --selector="((.username))" 
+		%v secret --addr="http://somewhere:8200" --token="dead-c0de" --path="secret/jenkins/dev/user/admin" --selector="((.username))" 
 	
-	Parse a file:
-		%v parse --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef" --path="secret/data/jenkins/dev/user/admin" --file="init.groovy"
-`, filename, filename, filename, filename, filename))
+	Print every secret under a path as a tree:
+		%v secret list --addr="http://somewhere:8200" --token="dead-c0de" --path="secret/jenkins" --recursive --format=tree
 
-	addr     = app.Flag("addr", "Vault address, like https://somewhere:8200 (VAULT_ADDR)").String()
-	insecure = app.Flag("skip-verify", "Skip SSL certificate verification (VAULT_SKIP_VERIFY)").Bool()
-	logLevel = app.Flag("log-level", "Logging level, one of: panic, fatal, error, warn, info, debug").Default("error").String()
+	Write a secret, reading one key from a file, only if it does not exist yet:
+		%v secret put --addr="http://somewhere:8200" --token="dead-c0de" --path="secret/app/db" --cas=0 username="app" password=@password.txt
+
+	Change one key of a secret, keeping the others:
+		%v secret patch --addr="http://somewhere:8200" --token="dead-c0de" --path="secret/app/db" password=-
+
+	Fetch a previous version of a KV v2 secret:
+		%v secret --addr="http://somewhere:8200" --token="dead-c0de" --path="secret/jenkins/dev/user/admin" --selector="((.password))" --version=3
+
+	Fetch dynamic database credentials, valid until the token or their lease expires:
+		%v secret --addr="http://somewhere:8200" --token="dead-c0de" --path="database/creds/readonly" --selector="((.username)):((.password))"
+
+	Parse a file:
+		%v parse --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef" --path="secret/jenkins/dev/user/admin" --file="init.groovy"
+
+	Parse a template file to a separate output file, leaving the template untouched:
+		%v parse --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef" --path="secret/jenkins/dev/user/admin" --file="init.groovy.tmpl" --out="init.groovy" --out-mode="0640"
+
+	Keep a parsed file up to date as its secrets change, until interrupted:
+		%v parse --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef" --path="secret/jenkins/dev/user/admin" --file="init.groovy.tmpl" --out="init.groovy" --watch --interval=1m --exec-on-change="systemctl reload jenkins"
+
+	Issue a TLS certificate from the PKI engine, writing the certificate, private key and CA chain:
+		%v pki issue --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef" --mount="pki" --role="web" --common-name="host.example.com" --cert-out="host.pem" --key-out="host-key.pem" --ca-out="ca.pem"
+
+	Serve secrets to the processes on this host through a unix socket, with one token shared by all of them:
+		%v serve --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef" --socket="/run/vault-helper/vault-helper.sock" --socket-mode="0660" --socket-owner="root:app"
+		curl --unix-socket /run/vault-helper/vault-helper.sock 'http://localhost/secret/secret/app/db?selector=((.password))'
+
+	Encrypt a file with a transit key to commit it to git, and decrypt it again:
+		%v transit encrypt --addr="http://somewhere:8200" --token="dead-c0de" --key="app" --in="app.key" --out="app.key.enc"
+		%v transit decrypt --addr="http://somewhere:8200" --token="dead-c0de" --key="app" --in="app.key.enc" --out="app.key"
+
+	Run a command with the keys of secrets as environment variables, like DB_USERNAME and DB_PASSWORD:
+		%v exec --addr="http://somewhere:8200" --role-id="dead-beef" --secret-id="ea7-beef" --path="secret/app/db" --env-prefix="DB_" -- ./app --config=app.conf
+`, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename, filename))
+
+	addr          = app.Flag("addr", "Vault address, like https://somewhere:8200 (VAULT_ADDR)").String()
+	insecure      = app.Flag("skip-verify", "Skip SSL certificate verification (VAULT_SKIP_VERIFY)").Bool()
+	caCert        = app.Flag("ca-cert", "A PEM file of CA certificates to verify the vault server with (VAULT_CACERT)").String()
+	caPath        = app.Flag("ca-path", "A directory of PEM files of CA certificates to verify the vault server with (VAULT_CAPATH)").String()
+	clientCert    = app.Flag("client-cert", "A PEM client certificate presented to the vault server (VAULT_CLIENT_CERT)").String()
+	clientKey     = app.Flag("client-key", "The PEM private key of --client-cert (VAULT_CLIENT_KEY)").String()
+	tlsServerName = app.Flag("tls-server-name", "The server name to verify the vault server certificate against (VAULT_TLS_SERVER_NAME)").String()
+	namespace     = app.Flag("namespace", "The Vault Enterprise namespace of every request, like 'team-a/app' (VAULT_NAMESPACE)").String()
+	authNamespace = app.Flag("auth-namespace", "The namespace to log in and renew or revoke tokens in, defaults to --namespace (VAULT_AUTH_NAMESPACE)").String()
+	logLevel      = app.Flag("log-level", "Logging level, one of: panic, fatal, error, warn, info, debug").Default("error").String()
+	logFormat     = app.Flag("log-format", "Logging format, one of: text, json").Default(logger.FormatText).Enum(logger.FormatText, logger.FormatJSON)
+	logFile       = app.Flag("log-file", "Append log entries to this file instead of writing them to STDERR").String()
 
 	token = app.Command("token", "Perform operations on a token")
 
 	// Create a token
-	tCreate         = token.Command("create", "Create a new token using the specified role_id and secret_id, printed to STDOUT.")
-	tCreateRoleId   = tCreate.Flag("role-id", "The Vault Approle Role Id (VAULT_ROLE_ID)").String()
-	tCreateSecretId = tCreate.Flag("secret-id", "The Vault Approle Secret Id (VAULT_SECRET_ID)").String()
+	tCreate      = token.Command("create", "Create a new token using the specified role_id and secret_id (or kubernetes role), printed to STDOUT.")
+	tCreateLogin = newLoginFlags(tCreate)
+
+	// Log in and store the token with the token helper
+	login  = app.Command("login", "Create a new token like 'token create', and store it in ~/.vault-token (or the token_helper of ~/.vault) for later commands.")
+	lLogin = newLoginFlags(login)
 
 	// Renew a token
-	tRenew      = token.Command("renew", "Renew an existing token. If it cannot be renewed, command returns non-zero exit status.")
-	tRenewToken = tRenew.Flag("token", "The token to be renewed (VAULT_TOKEN).").String()
+	tRenew          = token.Command("renew", "Renew an existing token. If it cannot be renewed, command returns non-zero exit status.")
+	tRenewToken     = tRenew.Flag("token", "The token to be renewed, '@file' to read it from a file or '-' from STDIN (VAULT_TOKEN).").String()
+	tRenewTokenFile = tRenew.Flag("token-file", "A file to read the token to be renewed from, or '-' for STDIN.").String()
 
 	// Revoke a token
-	tRevoke      = token.Command("revoke", "Revoke an existing token. If it cannot be revoked, command returns non-zero exit status.")
-	tRevokeToken = tRevoke.Flag("token", "The token to be revoked (VAULT_TOKEN).").String()
-
-	// Fetch a secret
-	secret    = app.Command("secret", "Fetch a given secret from Vault using the specified token, printing to STDOUT.")
-	sToken    = secret.Flag("token", "The token used to fetch the secret (VAULT_TOKEN).").String()
-	sPath     = secret.Flag("path", "The vault path for the secret, like 'secret/jenkins/dev/user/admin'.").Required().String()
-	sSelector = secret.Flag("selector", "The valid go template selector, like '((.username))'.").Required().String()
+	tRevoke          = token.Command("revoke", "Revoke an existing token. If it cannot be revoked, command returns non-zero exit status.")
+	tRevokeToken     = tRevoke.Flag("token", "The token to be revoked, '@file' to read it from a file or '-' from STDIN (VAULT_TOKEN).").String()
+	tRevokeTokenFile = tRevoke.Flag("token-file", "A file to read the token to be revoked from, or '-' for STDIN.").String()
+
+	// Fetch a secret, the default subcommand so 'secret --path=... --selector=...' keeps working
+	secret     = app.Command("secret", "Perform operations on secrets, fetching one when no subcommand is given.")
+	sToken     = secret.Flag("token", "The token used for the secret, '@file' to read it from a file or '-' from STDIN (VAULT_TOKEN).").String()
+	sTokenFile = secret.Flag("token-file", "A file to read the token used for the secret from, or '-' for STDIN.").String()
+	sPath      = secret.Flag("path", "The vault path for the secret, like 'secret/jenkins/dev/user/admin'.").Required().String()
+
+	secretGet = secret.Command("get", "Fetch a given secret from Vault using the specified token, printing to STDOUT.").Default()
+	sSelector = secretGet.Flag("selector", "The valid go template selector, like '((.username))'.").Required().String()
+	sVersion  = secretGet.Flag("version", "The KV v2 secret version to fetch, defaults to the latest version.").Int()
+
+	// List secrets
+	secretList = secret.Command("list", "List the keys under a path, where keys ending in '/' hold more keys, printing to STDOUT.")
+	slRecurse  = secretList.Flag("recursive", "Walk the keys ending in '/' as well, listing every secret under the path.").Bool()
+	slFormat   = secretList.Flag("format", "Output format, one of: plain, json, tree").Default(ListFormatPlain).Enum(ListFormatPlain, ListFormatJSON, ListFormatTree)
+
+	// Write a secret
+	secretPut = secret.Command("put", "Write a secret to Vault, replacing the keys it had.")
+	spCAS     = secretPut.Flag("cas", "Only write a KV v2 secret if this is its current version, 0 if it must not exist yet.").Default("-1").Int()
+	spData    = secretPut.Arg("data", "The keys of the secret, like 'key=value', 'key=@file' to read the value from a file or 'key=-' from STDIN.").Required().Strings()
+
+	secretPatch = secret.Command("patch", "Write keys to an existing secret in Vault, keeping the keys that are not given.")
+	sPatchCAS   = secretPatch.Flag("cas", "Only write a KV v2 secret if this is its current version, defaults to the version that was read.").Default("-1").Int()
+	sPatchData  = secretPatch.Arg("data", "The keys to write, like 'key=value', 'key=@file' to read the value from a file or 'key=-' from STDIN.").Required().Strings()
 
 	// Parse a file
 	parse     = app.Command("parse", "Parses all golang template placeholders like '((.username))' in a file, replaced with their secret value from Vault.")
-	pRoleId   = parse.Flag("role-id", "The Vault Approle Role Id (VAULT_ROLE_ID)").String()
-	pSecretId = parse.Flag("secret-id", "The Vault Approle Secret Id (VAULT_SECRET_ID)").String()
+	pLogin    = newLoginFlags(parse)
 	pPath     = parse.Flag("path", "The vault path for the secret, like 'secret/jenkins/dev/user/admin'.").Required().String()
 	pFile     = parse.Flag("file", "The file to perform parsing on.").Required().String()
+	pKeep     = parse.Flag("keep-token", "Leave the token to expire instead of revoking it when dynamic secrets were fetched, which would revoke them along with it. Use --no-keep-token to revoke it anyway.").Default("true").Bool()
+	pVersion  = parse.Flag("version", "The KV v2 secret version to parse with, defaults to the latest version.").Int()
+	pOut      = parse.Flag("out", "Write the parsed file here instead of overwriting --file, leaving the template untouched.").String()
+	pOutMode  = parse.Flag("out-mode", "The octal mode of the parsed file, like '0640'. Defaults to the mode of --file.").String()
+	pOutOwner = parse.Flag("out-owner", "The owner of the parsed file, like 'user', 'user:group' or '1000:1000'.").String()
+	pExec     = parse.Flag("exec-on-change", "A command run through the shell whenever the parsed file changed, like 'systemctl reload nginx'.").String()
+	pWatch    = parse.Flag("watch", "Keep running, renewing the token and re-parsing the file whenever the secrets change, until interrupted. Requires --out.").Bool()
+	pInterval = parse.Flag("interval", "How often to fetch the secrets again with --watch, like '30s' or '5m'.").Default("5m").Duration()
+
+	// Run a command with secrets in its environment
+	execute    = app.Command("exec", "Run a command with the keys of one or more secrets exported as environment variables, passing on signals and its exit status.")
+	eLogin     = newLoginFlags(execute)
+	ePaths     = execute.Flag("path", "The vault path for the secrets, like 'secret/jenkins/dev/user/admin'. Repeat for more paths, later paths win.").Required().Strings()
+	eEnvPrefix = execute.Flag("env-prefix", "A prefix for every environment variable name, like 'APP_'.").String()
+	eKeep      = execute.Flag("keep-token", "Leave the token to expire instead of revoking it when dynamic secrets were fetched, which would revoke them along with it. Use --no-keep-token to revoke it anyway.").Default("true").Bool()
+	eEnvUpper  = execute.Flag("upper-case", "Upper-case the environment variable names, use --no-upper-case to keep the keys as they are.").Default("true").Bool()
+	eCommand   = execute.Arg("command", "The command to run, and its arguments. Put it after '--' when it takes flags of its own.").Required().Strings()
+
+	// Issue a certificate
+	pki        = app.Command("pki", "Perform operations on a PKI secrets engine")
+	pkiIssue   = pki.Command("issue", "Issue a new certificate and private key from a PKI role, written to files.")
+	piLogin    = newLoginFlagsWithRole(pkiIssue, "auth-role")
+	piMount    = pkiIssue.Flag("mount", "The path the PKI secrets engine is mounted at.").Default("pki").String()
+	piRole     = pkiIssue.Flag("role", "The PKI role to issue the certificate from, like 'web'.").Required().String()
+	piCN       = pkiIssue.Flag("common-name", "The common name of the certificate, like 'host.example.com'.").Required().String()
+	piAltNames = pkiIssue.Flag("alt-names", "Comma-separated subject alternative names, like 'www.example.com,example.com'.").String()
+	piIPSans   = pkiIssue.Flag("ip-sans", "Comma-separated IP subject alternative names, like '10.0.0.1'.").String()
+	piTTL      = pkiIssue.Flag("ttl", "How long the certificate is valid for, like '72h'. Defaults to the TTL of the role.").String()
+	piCertOut  = pkiIssue.Flag("cert-out", "Write the certificate to this file.").Required().String()
+	piKeyOut   = pkiIssue.Flag("key-out", "Write the private key to this file, with mode 0600.").Required().String()
+	piCAOut    = pkiIssue.Flag("ca-out", "Write the CA chain to this file.").String()
+	piKeep     = pkiIssue.Flag("keep-token", "Leave the token to expire instead of revoking it when the certificate has a lease, which would revoke it along with it. Use --no-keep-token to revoke it anyway.").Default("true").Bool()
+	piOutOwner = pkiIssue.Flag("out-owner", "The owner of the written files, like 'user', 'user:group' or '1000:1000'.").String()
+
+	// Serve secrets to local processes
+	serve       = app.Command("serve", "Log in once and serve secrets on a unix socket with 'GET /secret/<path>?selector=((.key))', renewing the token until interrupted.")
+	srvLogin    = newLoginFlags(serve)
+	srvSocket   = serve.Flag("socket", "The unix socket to listen on, in a directory only writable by its owner.").Required().String()
+	srvMode     = serve.Flag("socket-mode", "The octal mode of the socket, like '0660' to let the socket's group connect.").Default(vault.DefaultSocketMode).String()
+	srvOwner    = serve.Flag("socket-owner", "The owner of the socket, like 'user', 'user:group' or '1000:1000'.").String()
+	srvCacheTTL = serve.Flag("cache-ttl", "How long to cache secrets without a lease, like '30s' or '5m'.").Default("5m").Duration()
+
+	// Encrypt and decrypt with a transit key
+	transit = app.Command("transit", "Perform operations on a transit secrets engine")
+
+	transitEncrypt = transit.Command("encrypt", "Encrypt the input with a transit key, printing the ciphertext like 'vault:v1:...' to STDOUT.")
+	teToken        = transitEncrypt.Flag("token", "The token used to encrypt, '@file' to read it from a file or '-' from STDIN (VAULT_TOKEN).").String()
+	teTokenFile    = transitEncrypt.Flag("token-file", "A file to read the token used to encrypt from, or '-' for STDIN.").String()
+	teMount        = transitEncrypt.Flag("mount", "The path the transit secrets engine is mounted at.").Default("transit").String()
+	teKey          = transitEncrypt.Flag("key", "The name of the transit key, like 'app'.").Required().String()
+	teIn           = transitEncrypt.Flag("in", "A file to encrypt, which can be binary, or '-' for STDIN.").Default("-").String()
+	teOut          = transitEncrypt.Flag("out", "Write the ciphertext to this file instead of STDOUT.").Default("-").String()
+
+	transitDecrypt = transit.Command("decrypt", "Decrypt a ciphertext like 'vault:v1:...' with a transit key, printing the plaintext to STDOUT.")
+	tdToken        = transitDecrypt.Flag("token", "The token used to decrypt, '@file' to read it from a file or '-' from STDIN (VAULT_TOKEN).").String()
+	tdTokenFile    = transitDecrypt.Flag("token-file", "A file to read the token used to decrypt from, or '-' for STDIN.").String()
+	tdMount        = transitDecrypt.Flag("mount", "The path the transit secrets engine is mounted at.").Default("transit").String()
+	tdKey          = transitDecrypt.Flag("key", "The name of the transit key, like 'app'.").Required().String()
+	tdIn           = transitDecrypt.Flag("in", "A file holding the ciphertext, or '-' for STDIN.").Default("-").String()
+	tdOut          = transitDecrypt.Flag("out", "Write the plaintext to this file, with mode 0600, instead of STDOUT.").Default("-").String()
 
 	// Version
 	version = app.Command("version", "Display version and build information")
 )
 
-func Run(ctx context.Context, args []string) {
+func Run(ctx context.Context, args []string) int {
+	var err error
+
 	switch kingpin.MustParse(app.Parse(args[1:])) {
 	case tCreate.FullCommand():
-		logger.SetLoggingLevel(*logLevel)
+		setupLogging()
 		logger.Infof("Create token ...")
-		fmt.Println(vault.NewVaultClient(ctx, GetEnvValue(EnvVaultAddr, *addr), GetBoolEnvValue(EnvVaultInsecure, *insecure)).CreateToken(GetEnvValue(EnvVaultRoleId, *tCreateRoleId), GetEnvValue(EnvVaultSecretId, *tCreateSecretId)))
+		err = createToken(ctx)
+
+	case login.FullCommand():
+		setupLogging()
+		logger.Infof("Log in ...")
+		err = loginToken(ctx)
 
 	case tRenew.FullCommand():
-		logger.SetLoggingLevel(*logLevel)
+		setupLogging()
 		logger.Infof("Renew token ...")
-		fmt.Println(vault.NewVaultClient(ctx, GetEnvValue(EnvVaultAddr, *addr), GetBoolEnvValue(EnvVaultInsecure, *insecure)).RenewToken(GetEnvValue(EnvVaultToken, *tRenewToken)))
+		err = renewToken(ctx)
 
 	case tRevoke.FullCommand():
-		logger.SetLoggingLevel(*logLevel)
+		setupLogging()
 		logger.Infof("Revoke token ...")
-		vault.NewVaultClient(ctx, GetEnvValue(EnvVaultAddr, *addr), GetBoolEnvValue(EnvVaultInsecure, *insecure)).RevokeToken(GetEnvValue(EnvVaultToken, *tRevokeToken))
+		err = revokeToken(ctx)
 
-	case secret.FullCommand():
-		logger.SetLoggingLevel(*logLevel)
+	case secretGet.FullCommand():
+		setupLogging()
 		logger.Infof("Fetch secrets from %v ...", *sPath)
-		fmt.Println(vault.NewVaultClient(ctx, GetEnvValue(EnvVaultAddr, *addr), GetBoolEnvValue(EnvVaultInsecure, *insecure)).FetchSecret(GetEnvValue(EnvVaultToken, *sToken), *sPath, *sSelector))
+		err = fetchSecret(ctx)
+
+	case secretList.FullCommand():
+		setupLogging()
+		logger.Infof("List secrets under %v ...", *sPath)
+		err = listSecrets(ctx)
+
+	case secretPut.FullCommand():
+		setupLogging()
+		logger.Infof("Write secret %v ...", *sPath)
+		err = writeSecret(ctx, false)
+
+	case secretPatch.FullCommand():
+		setupLogging()
+		logger.Infof("Patch secret %v ...", *sPath)
+		err = writeSecret(ctx, true)
 
 	case parse.FullCommand():
-		logger.SetLoggingLevel(*logLevel)
+		setupLogging()
 		logger.Infof("Parse file %v using secrets from %v...", *pFile, *pPath)
-		vault.NewVaultClient(ctx, GetEnvValue(EnvVaultAddr, *addr), GetBoolEnvValue(EnvVaultInsecure, *insecure)).ParseFile(GetEnvValue(EnvVaultRoleId, *pRoleId), GetEnvValue(EnvVaultSecretId, *pSecretId), *pPath, *pFile)
+		err = parseFile(ctx)
+
+	case execute.FullCommand():
+		setupLogging()
+		logger.Infof("Run %v with secrets from %v ...", (*eCommand)[0], *ePaths)
+		err = execCommand(ctx)
+
+	case pkiIssue.FullCommand():
+		setupLogging()
+		logger.Infof("Issue certificate for %v from %v/issue/%v ...", *piCN, *piMount, *piRole)
+		err = issueCertificate(ctx)
+
+	case serve.FullCommand():
+		setupLogging()
+		logger.Infof("Serve secrets on %v ...", *srvSocket)
+		err = serveSecrets(ctx)
+
+	case transitEncrypt.FullCommand():
+		setupLogging()
+		logger.Infof("Encrypt with %v/encrypt/%v ...", *teMount, *teKey)
+		err = encryptTransit(ctx)
+
+	case transitDecrypt.FullCommand():
+		setupLogging()
+		logger.Infof("Decrypt with %v/decrypt/%v ...", *tdMount, *tdKey)
+		err = decryptTransit(ctx)
 
 	case version.FullCommand():
-		logger.SetLoggingLevel(*logLevel)
+		setupLogging()
 		fmt.Printf("%v v%v built on %v\n", filename, BuildVersion, BuildTimestamp)
 	}
+
+	// The child of 'exec' reports its own errors, we only pass its exit status on
+	var childExitError *ChildExitError
+	if err != nil && !errors.As(err, &childExitError) {
+		logger.WithFields(ErrorFields(err)).Errorf("%v", err)
+	}
+
+	return ExitCode(err)
+}
+
+// Maps an error returned from the vault pkg to the exit status of the process, so callers can tell apart bad input,
+// an unreachable vault, and vault refusing the request.
+func ExitCode(err error) int {
+	var (
+		validationError *vault.ValidationError
+		httpError       *vault.HTTPError
+		responseError   *vault.ResponseError
+		templateError   *vault.TemplateError
+		commandError    *vault.CommandError
+		childExitError  *ChildExitError
+	)
+
+	switch {
+	case err == nil:
+		return ExitCodeOK
+	case errors.As(err, &childExitError):
+		return childExitError.Code
+	case errors.As(err, &validationError):
+		return ExitCodeValidation
+	case errors.As(err, &httpError):
+		return ExitCodeHTTP
+	case errors.As(err, &responseError):
+		return ExitCodeResponse
+	case errors.As(err, &templateError):
+		return ExitCodeTemplate
+	case errors.As(err, &commandError):
+		return ExitCodeCommand
+	default:
+		return ExitCodeError
+	}
+}
+
+// The fields of the vault request an error came from, like its request id, path and HTTP status, logged along with the
+// error so it can be matched with the debug entries of the request and the audit log of vault
+func ErrorFields(err error) logger.Fields {
+	var (
+		httpError     *vault.HTTPError
+		responseError *vault.ResponseError
+	)
+
+	fields := logger.Fields{}
+
+	switch {
+	case errors.As(err, &responseError):
+		fields["method"] = responseError.Method
+		fields["path"] = responseError.Path
+		fields["status"] = responseError.StatusCode
+		fields["duration"] = responseError.Duration.String()
+
+		if responseError.RequestId != "" {
+			fields["request_id"] = responseError.RequestId
+		}
+	case errors.As(err, &httpError) && httpError.Path != "":
+		fields["method"] = httpError.Method
+		fields["path"] = httpError.Path
+		fields["duration"] = httpError.Duration.String()
+	}
+
+	return fields
+}
+
+func setupLogging() {
+	logger.SetLoggingLevel(*logLevel)
+	logger.SetLoggingFormat(*logFormat)
+
+	if *logFile != "" {
+		logger.SetLoggingFile(*logFile)
+	}
+}
+
+func newVaultClient(ctx context.Context) (*vault.Client, error) {
+	client, err := vault.NewVaultClient(ctx, GetEnvValue(EnvVaultAddr, *addr), vault.TLSConfig{
+		CACert:     GetEnvValue(EnvVaultCACert, *caCert),
+		CAPath:     GetEnvValue(EnvVaultCAPath, *caPath),
+		ClientCert: GetEnvValue(EnvVaultClientCert, *clientCert),
+		ClientKey:  GetEnvValue(EnvVaultClientKey, *clientKey),
+		ServerName: GetEnvValue(EnvVaultTLSServerName, *tlsServerName),
+		Insecure:   GetBoolEnvValue(EnvVaultInsecure, *insecure),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Namespace = GetEnvValue(EnvVaultNamespace, *namespace)
+	client.AuthNamespace = GetEnvValue(EnvVaultAuthNamespace, *authNamespace)
+	return client, nil
+}
+
+func createToken(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	roleId, secretId, err := tCreateLogin.credentials(client)
+	if err != nil {
+		return err
+	}
+
+	token, err := client.CreateToken(roleId, secretId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(token)
+	return nil
+}
+
+func loginToken(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	roleId, secretId, err := lLogin.credentials(client)
+	if err != nil {
+		return err
+	}
+
+	helper, err := newTokenHelper()
+	if err != nil {
+		return err
+	}
+
+	token, err := client.CreateToken(roleId, secretId)
+	if err != nil {
+		return err
+	}
+
+	err = helper.store(token)
+	if err != nil {
+		// Do not leave a token behind that nobody knows about
+		_ = client.RevokeToken(token)
+
+		return err
+	}
+
+	fmt.Printf("Success! The token was stored in %v, and is used when no token is given.\n", helper)
+	return nil
+}
+
+func renewToken(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := getToken(*tRenewToken, *tRenewTokenFile)
+	if err != nil {
+		return err
+	}
+
+	token, err = client.RenewToken(token)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(token)
+	return nil
+}
+
+func revokeToken(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := getToken(*tRevokeToken, *tRevokeTokenFile)
+	if err != nil {
+		return err
+	}
+
+	return client.RevokeToken(token)
+}
+
+func fetchSecret(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	client.Version = *sVersion
+
+	token, err := getToken(*sToken, *sTokenFile)
+	if err != nil {
+		return err
+	}
+
+	secret, err := client.FetchSecret(token, *sPath, *sSelector)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(secret)
+	return nil
+}
+
+func listSecrets(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := getToken(*sToken, *sTokenFile)
+	if err != nil {
+		return err
+	}
+
+	keys, err := client.ListSecrets(token, *sPath, *slRecurse)
+	if err != nil {
+		return err
+	}
+
+	return printSecretKeys(os.Stdout, *sPath, keys, *slFormat)
+}
+
+func writeSecret(ctx context.Context, patch bool) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := getToken(*sToken, *sTokenFile)
+	if err != nil {
+		return err
+	}
+
+	arguments, cas, write := *spData, *spCAS, client.PutSecret
+	if patch {
+		arguments, cas, write = *sPatchData, *sPatchCAS, client.PatchSecret
+	}
+
+	data, err := parseSecretData(arguments)
+	if err != nil {
+		return err
+	}
+
+	version, err := write(token, *sPath, data, cas)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 {
+		fmt.Printf("Success! Version %v of %v was written.\n", version, *sPath)
+	} else {
+		fmt.Printf("Success! %v was written.\n", *sPath)
+	}
+	return nil
+}
+
+func parseFile(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	roleId, secretId, err := pLogin.credentials(client)
+	if err != nil {
+		return err
+	}
+
+	client.Version = *pVersion
+	client.RevokeLeasedToken = !*pKeep
+	client.Out = *pOut
+	client.OutMode = *pOutMode
+	client.OutOwner = *pOutOwner
+	client.ExecOnChange = *pExec
+
+	if *pWatch {
+		// Watch until we are asked to stop, so the token gets revoked on the way out
+		watchCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return client.WatchFile(watchCtx, roleId, secretId, *pPath, *pFile, *pInterval)
+	}
+
+	return client.ParseFile(roleId, secretId, *pPath, *pFile)
+}
+
+func execCommand(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	roleId, secretId, err := eLogin.credentials(client)
+	if err != nil {
+		return err
+	}
+
+	client.EnvPrefix = *eEnvPrefix
+	client.EnvUpperCase = *eEnvUpper
+	client.RevokeLeasedToken = !*eKeep
+
+	environment, err := client.FetchEnvironment(roleId, secretId, *ePaths)
+	if err != nil {
+		return err
+	}
+
+	return runChild(*eCommand, environment)
+}
+
+func issueCertificate(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	roleId, secretId, err := piLogin.credentials(client)
+	if err != nil {
+		return err
+	}
+
+	client.OutOwner = *piOutOwner
+	client.RevokeLeasedToken = !*piKeep
+
+	parameters := map[string]string{"common_name": *piCN}
+	if *piAltNames != "" {
+		parameters["alt_names"] = *piAltNames
+	}
+	if *piIPSans != "" {
+		parameters["ip_sans"] = *piIPSans
+	}
+	if *piTTL != "" {
+		parameters["ttl"] = *piTTL
+	}
+
+	files := vault.CertificateFiles{Cert: *piCertOut, Key: *piKeyOut, CA: *piCAOut}
+	issuePath := fmt.Sprintf("%v/issue/%v", strings.Trim(*piMount, "/"), *piRole)
+
+	return client.IssueCertificate(roleId, secretId, issuePath, parameters, files)
+}
+
+func serveSecrets(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	roleId, secretId, err := srvLogin.credentials(client)
+	if err != nil {
+		return err
+	}
+
+	client.SocketMode = *srvMode
+	client.SocketOwner = *srvOwner
+
+	// Serve until we are asked to stop, so the token gets revoked on the way out
+	serveCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return client.Serve(serveCtx, roleId, secretId, *srvSocket, *srvCacheTTL)
+}
+
+func encryptTransit(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := getToken(*teToken, *teTokenFile)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := readTransitInput(*teIn)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := client.TransitEncrypt(token, *teMount, *teKey, plaintext)
+	if err != nil {
+		return err
+	}
+
+	return writeTransitOutput(*teOut, []byte(ciphertext+"\n"))
+}
+
+func decryptTransit(ctx context.Context) error {
+	client, err := newVaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := getToken(*tdToken, *tdTokenFile)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := readTransitInput(*tdIn)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := client.TransitDecrypt(token, *tdMount, *tdKey, string(ciphertext))
+	if err != nil {
+		return err
+	}
+
+	return writeTransitOutput(*tdOut, plaintext)
 }
 
 func GetEnvValue(environmentKey, defaultValue string) string {
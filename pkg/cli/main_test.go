@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestErrorFields(t *testing.T) {
+	// Vault refusing a request is logged with the request id, path and status of the response
+	err := fmt.Errorf("Could not fetch secret: %w", &vault.ResponseError{Method: "GET", Path: "/v1/kv/db", RequestId: "c0ffee", Duration: time.Millisecond, StatusCode: 403})
+	assert.Equal(t, logger.Fields{"method": "GET", "path": "/v1/kv/db", "request_id": "c0ffee", "status": 403, "duration": "1ms"}, ErrorFields(err))
+
+	// The request id is left out when the response had none
+	err = &vault.ResponseError{Method: "GET", Path: "/v1/kv/db", Duration: time.Millisecond, StatusCode: 404}
+	assert.Equal(t, logger.Fields{"method": "GET", "path": "/v1/kv/db", "status": 404, "duration": "1ms"}, ErrorFields(err))
+
+	// Vault not answering is logged with the request that was sent
+	err = &vault.HTTPError{Method: "POST", Path: "/v1/auth/approle/login", Duration: time.Second, Err: errors.New("connection refused")}
+	assert.Equal(t, logger.Fields{"method": "POST", "path": "/v1/auth/approle/login", "duration": "1s"}, ErrorFields(err))
+
+	// Other errors have no request to describe
+	assert.Equal(t, logger.Fields{}, ErrorFields(&vault.ValidationError{Err: errors.New("Path cannot be empty")}))
+	assert.Equal(t, logger.Fields{}, ErrorFields(&vault.HTTPError{Err: errors.New("connection refused")}))
+}
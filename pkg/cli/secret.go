@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+// Output formats of 'secret list'
+const (
+	ListFormatPlain = "plain"
+	ListFormatJSON  = "json"
+	ListFormatTree  = "tree"
+)
+
+// Prints the keys listed under root, one per line, as a JSON array, or as a tree like the 'tree' command does
+func printSecretKeys(w io.Writer, root string, keys []string, format string) error {
+	switch format {
+	case ListFormatJSON:
+		if keys == nil {
+			keys = []string{}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(keys)
+	case ListFormatTree:
+		fmt.Fprintln(w, strings.TrimSuffix(root, "/")+"/")
+		printSecretTree(w, "", keys)
+		return nil
+	default:
+		for _, key := range keys {
+			fmt.Fprintln(w, key)
+		}
+		return nil
+	}
+}
+
+// Prints sorted keys like 'dev/user/admin' as branches of a tree, each level indented under its parent
+func printSecretTree(w io.Writer, indent string, keys []string) {
+	// Group the keys by their first segment, keeping the order they came in
+	var names []string
+	children := make(map[string][]string)
+	for _, key := range keys {
+		parts := strings.SplitAfterN(key, "/", 2)
+
+		if _, ok := children[parts[0]]; !ok {
+			names = append(names, parts[0])
+			children[parts[0]] = nil
+		}
+		if len(parts) == 2 && parts[1] != "" {
+			children[parts[0]] = append(children[parts[0]], parts[1])
+		}
+	}
+
+	for i, name := range names {
+		branch, nested := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, nested = "└── ", "    "
+		}
+
+		fmt.Fprintln(w, indent+branch+name)
+		printSecretTree(w, indent+nested, children[name])
+	}
+}
+
+// Parses the keys of 'secret put' and 'secret patch', like 'key=value'. The value can also be '@path' to read it from
+// a file, or '-' to read it from STDIN, so it never shows up in the process list or shell history. Values read are
+// kept as they are, trailing newline included.
+func parseSecretData(arguments []string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+
+	for _, argument := range arguments {
+		parts := strings.SplitN(argument, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, &vault.ValidationError{Err: fmt.Errorf("Could not parse secret data '%v', expected one like 'key=value'", argument)}
+		}
+
+		value, err := readSecretValue(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		data[parts[0]] = value
+	}
+
+	return data, nil
+}
+
+func readSecretValue(value string) (string, error) {
+	switch {
+	case value == "-":
+		content, err := readStdin("value")
+		if err != nil {
+			return "", err
+		}
+
+		return string(content), nil
+	case strings.HasPrefix(value, "@"):
+		content, err := os.ReadFile(strings.TrimPrefix(value, "@"))
+		if err != nil {
+			return "", &vault.ValidationError{Err: fmt.Errorf("Could not read value file '%v': %w", strings.TrimPrefix(value, "@"), err)}
+		}
+
+		return string(content), nil
+	default:
+		return value, nil
+	}
+}
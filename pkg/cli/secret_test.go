@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestParseSecretData(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "password")
+	stdin := path.Join(dir, "stdin")
+	assert.Nil(t, os.WriteFile(file, []byte("s3cr3t\n"), 0600))
+	assert.Nil(t, os.WriteFile(stdin, []byte("s3cr3t\n"), 0600))
+
+	// Whatever is on STDIN of the test is put back once we are done
+	defer func(file *os.File) { os.Stdin, stdinRead = file, false }(os.Stdin)
+
+	// Nothing given
+	data, err := parseSecretData(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{}, data)
+
+	// Keys given like key=value
+	data, err = parseSecretData([]string{"user=admin", "port=5432"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "admin", "port": "5432"}, data)
+
+	// Values can hold '=' themselves, or be empty
+	data, err = parseSecretData([]string{"dsn=host=db user=admin", "comment="})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"dsn": "host=db user=admin", "comment": ""}, data)
+
+	// The last of the same key wins
+	data, err = parseSecretData([]string{"user=admin", "user=root"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "root"}, data)
+
+	// Value read from a file is kept as it is, trailing newline included
+	data, err = parseSecretData([]string{"password=@" + file})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"password": "s3cr3t\n"}, data)
+
+	// Value read from STDIN is kept as it is as well
+	os.Stdin, err = os.Open(stdin)
+	assert.Nil(t, err)
+	defer os.Stdin.Close()
+	stdinRead = false
+
+	data, err = parseSecretData([]string{"user=admin", "password=-"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "admin", "password": "s3cr3t\n"}, data)
+
+	// STDIN can only be read once
+	var validationError *vault.ValidationError
+
+	_, err = parseSecretData([]string{"user=-"})
+	assert.True(t, errors.As(err, &validationError), "Expected parseSecretData() to return a ValidationError for reading STDIN twice, got: %v", err)
+
+	// Missing '='
+	_, err = parseSecretData([]string{"user"})
+	assert.True(t, errors.As(err, &validationError), "Expected parseSecretData() to return a ValidationError for a key without '=', got: %v", err)
+
+	// Empty key
+	_, err = parseSecretData([]string{"=admin"})
+	assert.True(t, errors.As(err, &validationError), "Expected parseSecretData() to return a ValidationError for an empty key, got: %v", err)
+
+	// File that cannot be read
+	_, err = parseSecretData([]string{"password=@" + path.Join(dir, "missing")})
+	assert.True(t, errors.As(err, &validationError), "Expected parseSecretData() to return a ValidationError for a missing file, got: %v", err)
+}
+
+// The output of printSecretKeys for the keys listed under 'secret/jenkins'
+func printedSecretKeys(t *testing.T, keys []string, format string) string {
+	var out bytes.Buffer
+
+	assert.Nil(t, printSecretKeys(&out, "secret/jenkins", keys, format))
+	return out.String()
+}
+
+func TestPrintSecretKeys(t *testing.T) {
+	keys := []string{"dev/db", "dev/user/admin", "dev/user/ci", "prod/db", "token"}
+
+	// Plain, one key per line
+	assert.Equal(t, "dev/db\ndev/user/admin\ndev/user/ci\nprod/db\ntoken\n", printedSecretKeys(t, keys, ListFormatPlain))
+	assert.Equal(t, "", printedSecretKeys(t, nil, ListFormatPlain))
+
+	// JSON, an empty array rather than null when there are no keys
+	assert.Equal(t, "[\n  \"db\",\n  \"user/\"\n]\n", printedSecretKeys(t, []string{"db", "user/"}, ListFormatJSON))
+	assert.Equal(t, "[]\n", printedSecretKeys(t, nil, ListFormatJSON))
+
+	// Tree, every level indented under its parent
+	assert.Equal(t, `secret/jenkins/
+├── dev/
+│   ├── db
+│   └── user/
+│       ├── admin
+│       └── ci
+├── prod/
+│   └── db
+└── token
+`, printedSecretKeys(t, keys, ListFormatTree))
+
+	// Tree, not recursive
+	assert.Equal(t, "secret/jenkins/\n├── db\n└── user/\n", printedSecretKeys(t, []string{"db", "user/"}, ListFormatTree))
+
+	// Tree, without keys
+	assert.Equal(t, "secret/jenkins/\n", printedSecretKeys(t, nil, ListFormatTree))
+}
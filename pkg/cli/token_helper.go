@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+const (
+	EnvVaultConfigPath = "VAULT_CONFIG_PATH"
+)
+
+var (
+	// Matches the token_helper setting of the vault CLI config, in either its HCL or JSON form
+	tokenHelperSetting = regexp.MustCompile(`(?m)(?:^|[{,])\s*"?token_helper"?\s*[=:]\s*"([^"]*)"`)
+)
+
+// Where the token is kept between invocations when none is given, the same as the official vault CLI. By default that
+// is ~/.vault-token, unless the vault CLI config (~/.vault, or VAULT_CONFIG_PATH) sets an external token_helper.
+type tokenHelper interface {
+	get() (string, error)
+	store(token string) error
+	String() string
+}
+
+func newTokenHelper() (tokenHelper, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("Could not find the home directory for the token helper: %w", err)
+	}
+
+	config := GetEnvValue(EnvVaultConfigPath, path.Join(home, ".vault"))
+
+	content, err := os.ReadFile(config)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Could not read vault config '%v': %w", config, err)
+	}
+
+	if match := tokenHelperSetting.FindSubmatch(content); match != nil {
+		binary := string(match[1])
+		if !path.IsAbs(binary) {
+			return nil, fmt.Errorf("The token_helper '%v' in vault config '%v' must be an absolute path", binary, config)
+		}
+
+		return &externalTokenHelper{binary: binary}, nil
+	}
+
+	return &fileTokenHelper{file: path.Join(home, ".vault-token")}, nil
+}
+
+// The default token helper of the vault CLI, keeping the token in a file only the user can read
+type fileTokenHelper struct {
+	file string
+}
+
+func (h *fileTokenHelper) get() (string, error) {
+	content, err := os.ReadFile(h.file)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Could not read token from '%v': %w", h.file, err)
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+func (h *fileTokenHelper) store(token string) error {
+	// Replaced rather than truncated, so the token is never readable through the mode of an existing file
+	err := vault.WriteFileAtomic(h.file, []byte(token), 0600)
+	if err != nil {
+		return fmt.Errorf("Could not store token in '%v': %w", h.file, err)
+	}
+
+	return nil
+}
+
+func (h *fileTokenHelper) String() string {
+	return h.file
+}
+
+// A token helper program, which is run with 'get' to print the token, or 'store' to store the token read from STDIN
+type externalTokenHelper struct {
+	binary string
+}
+
+func (h *externalTokenHelper) get() (string, error) {
+	var stdout bytes.Buffer
+
+	err := h.run("get", nil, &stdout)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (h *externalTokenHelper) store(token string) error {
+	return h.run("store", strings.NewReader(token), nil)
+}
+
+func (h *externalTokenHelper) run(operation string, stdin *strings.Reader, stdout *bytes.Buffer) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(h.binary, operation)
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Token helper '%v %v' failed: %w: %v", h.binary, operation, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+func (h *externalTokenHelper) String() string {
+	return "token helper " + h.binary
+}
+
+// Looks up the token from its environment variable, flag or file, falling back to the token helper when none was given
+func getToken(value, file string) (string, error) {
+	token, err := getCredential(EnvVaultToken, value, file)
+	if err != nil || token != "" {
+		return token, err
+	}
+
+	helper, err := newTokenHelper()
+	if err != nil {
+		return "", err
+	}
+
+	return helper.get()
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"runtime"
+	"testing"
+)
+
+// The token_helper setting found in a vault CLI config, or "" when there is none
+func tokenHelperSettingOf(config string) string {
+	match := tokenHelperSetting.FindStringSubmatch(config)
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+func TestTokenHelperSetting(t *testing.T) {
+	// HCL
+	assert.Equal(t, "/usr/local/bin/vault-token-helper", tokenHelperSettingOf(`token_helper = "/usr/local/bin/vault-token-helper"`))
+
+	// HCL, indented among other settings
+	assert.Equal(t, "/opt/helper", tokenHelperSettingOf("disable_mlock = true\n  token_helper=\"/opt/helper\"\n"))
+
+	// JSON
+	assert.Equal(t, "/usr/local/bin/vault-token-helper", tokenHelperSettingOf(`{"token_helper": "/usr/local/bin/vault-token-helper"}`))
+
+	// JSON, after other settings
+	assert.Equal(t, "/opt/helper", tokenHelperSettingOf(`{"disable_mlock": true, "token_helper": "/opt/helper"}`))
+
+	// JSON, on its own line
+	assert.Equal(t, "/opt/helper", tokenHelperSettingOf("{\n  \"token_helper\": \"/opt/helper\"\n}"))
+
+	// Commented out
+	assert.Equal(t, "", tokenHelperSettingOf(`# token_helper = "/opt/helper"`))
+
+	// Another setting starting the same
+	assert.Equal(t, "", tokenHelperSettingOf(`token_helper_timeout = "5s"`))
+
+	// Empty config
+	assert.Equal(t, "", tokenHelperSettingOf(""))
+}
+
+func TestNewTokenHelper(t *testing.T) {
+	home := t.TempDir()
+	config := path.Join(home, "vault.hcl")
+	binary := path.Join(home, "vault-token-helper")
+
+	// Whatever is in the environment of the test is put back once we are done
+	defer func(home, userProfile, configPath string) {
+		_ = os.Setenv("HOME", home)
+		_ = os.Setenv("USERPROFILE", userProfile)
+		_ = os.Setenv(EnvVaultConfigPath, configPath)
+	}(os.Getenv("HOME"), os.Getenv("USERPROFILE"), os.Getenv(EnvVaultConfigPath))
+	assert.Nil(t, os.Setenv("HOME", home))
+	assert.Nil(t, os.Setenv("USERPROFILE", home))
+	assert.Nil(t, os.Setenv(EnvVaultConfigPath, config))
+
+	// Without a config, the token is kept in ~/.vault-token
+	helper, err := newTokenHelper()
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(home, ".vault-token"), helper.String())
+
+	// A token_helper has to be an absolute path
+	assert.Nil(t, os.WriteFile(config, []byte(`token_helper = "vault-token-helper"`), 0600))
+	_, err = newTokenHelper()
+	assert.NotNil(t, err, "Expected newTokenHelper() to return error for a relative token_helper")
+
+	// An absolute token_helper is run instead
+	assert.Nil(t, os.WriteFile(config, []byte(`token_helper = "`+binary+`"`), 0600))
+	helper, err = newTokenHelper()
+	assert.Nil(t, err)
+	assert.Equal(t, "token helper "+binary, helper.String())
+}
+
+func TestFileTokenHelper(t *testing.T) {
+	file := path.Join(t.TempDir(), ".vault-token")
+	helper := &fileTokenHelper{file: file}
+
+	// No token stored yet
+	token, err := helper.get()
+	assert.Nil(t, err)
+	assert.Equal(t, "", token)
+
+	// An existing file readable by others is replaced by one only the user can read
+	assert.Nil(t, os.WriteFile(file, []byte("old-c0de"), 0644))
+	assert.Nil(t, helper.store("dead-c0de"))
+
+	token, err = helper.get()
+	assert.Nil(t, err)
+	assert.Equal(t, "dead-c0de", token)
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(file)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
+
+func TestGetToken(t *testing.T) {
+	home := t.TempDir()
+
+	// Whatever is in the environment of the test is put back once we are done
+	defer func(home, userProfile, configPath, token string) {
+		_ = os.Setenv("HOME", home)
+		_ = os.Setenv("USERPROFILE", userProfile)
+		_ = os.Setenv(EnvVaultConfigPath, configPath)
+		_ = os.Setenv(EnvVaultToken, token)
+	}(os.Getenv("HOME"), os.Getenv("USERPROFILE"), os.Getenv(EnvVaultConfigPath), os.Getenv(EnvVaultToken))
+	assert.Nil(t, os.Setenv("HOME", home))
+	assert.Nil(t, os.Setenv("USERPROFILE", home))
+	assert.Nil(t, os.Setenv(EnvVaultConfigPath, path.Join(home, "missing.hcl")))
+	assert.Nil(t, os.Setenv(EnvVaultToken, ""))
+
+	// Nothing given and nothing stored
+	token, err := getToken("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "", token)
+
+	// Falls back to ~/.vault-token, like the vault CLI
+	assert.Nil(t, os.WriteFile(path.Join(home, ".vault-token"), []byte("dead-c0de\n"), 0600))
+	token, err = getToken("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "dead-c0de", token)
+
+	// The token given wins over the stored token
+	token, err = getToken("ea7-c0de", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "ea7-c0de", token)
+
+	// The environment variable wins over both
+	assert.Nil(t, os.Setenv(EnvVaultToken, "f00d-c0de"))
+	token, err = getToken("ea7-c0de", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "f00d-c0de", token)
+}
+
+func TestExternalTokenHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The token helper is a POSIX shell script")
+	}
+
+	dir := t.TempDir()
+	binary := path.Join(dir, "vault-token-helper")
+	script := `#!/bin/sh
+case "$1" in
+  get) cat "` + dir + `/token" 2>/dev/null ;;
+  store) cat > "` + dir + `/token" ;;
+  *) exit 1 ;;
+esac
+`
+	assert.Nil(t, os.WriteFile(binary, []byte(script), 0700))
+
+	// Stores the token on STDIN of 'store', and gets it from STDOUT of 'get'
+	helper := &externalTokenHelper{binary: binary}
+	assert.Nil(t, helper.store("dead-c0de"))
+
+	token, err := helper.get()
+	assert.Nil(t, err)
+	assert.Equal(t, "dead-c0de", token)
+
+	// A helper that cannot be run is an error
+	_, err = (&externalTokenHelper{binary: path.Join(dir, "missing")}).get()
+	assert.NotNil(t, err, "Expected get() to return error for a missing token helper")
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+// Reads the input of 'transit encrypt' or 'transit decrypt' as is, from a file or '-' for STDIN. It can be binary, the
+// base64 vault wants is taken care of by the vault pkg.
+func readTransitInput(file string) ([]byte, error) {
+	if file == "-" {
+		return readStdin("input")
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, &vault.ValidationError{Err: fmt.Errorf("Could not read input file '%v': %w", file, err)}
+	}
+
+	return content, nil
+}
+
+// Writes the output of 'transit encrypt' or 'transit decrypt' to a file only its owner can read, whatever the mode of
+// the file it replaces, or '-' for STDOUT
+func writeTransitOutput(file string, content []byte) error {
+	if file == "-" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+
+	return vault.WriteFileAtomic(file, content, 0600)
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// Extra fields to log alongside the message, like the vault request id or HTTP status
+type Fields map[string]interface{}
+
+// A log entry with fields, next to the 'src' field every entry has
+type Entry struct {
+	fields log.Fields
+}
+
+func WithFields(fields Fields) *Entry {
+	entry := &Entry{
+		fields: log.Fields{
+			"src": filename,
+		},
+	}
+
+	for key, value := range fields {
+		entry.fields[key] = value
+	}
+
+	return entry
+}
+
+func (e *Entry) Debugf(format string, args ...interface{}) {
+	log.WithFields(e.fields).Debug(RedactString(fmt.Sprintf(format, args...)))
+}
+
+func (e *Entry) Infof(format string, args ...interface{}) {
+	log.WithFields(e.fields).Info(RedactString(fmt.Sprintf(format, args...)))
+}
+
+func (e *Entry) Warnf(format string, args ...interface{}) {
+	log.WithFields(e.fields).Warn(RedactString(fmt.Sprintf(format, args...)))
+}
+
+func (e *Entry) Errorf(format string, args ...interface{}) {
+	log.WithFields(e.fields).Error(RedactString(fmt.Sprintf(format, args...)))
+}
+
+func (e *Entry) Fatalf(format string, args ...interface{}) {
+	log.WithFields(e.fields).Fatal(RedactString(fmt.Sprintf(format, args...)))
+}
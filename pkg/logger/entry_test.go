@@ -0,0 +1,37 @@
+package logger_test
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+func TestWithFields(t *testing.T) {
+	file := path.Join(t.TempDir(), "vault-helper.log")
+
+	logger.SetLoggingLevel("debug")
+	logger.SetLoggingFormat(logger.FormatJSON)
+	logger.SetLoggingFile(file)
+	defer logger.SetLoggingFormat(logger.FormatText)
+	defer logger.SetLoggingFile(os.DevNull)
+
+	logger.Redact("s.c0ffee")
+	logger.WithFields(logger.Fields{"request_id": "abc-123", "status": 200}).Infof("Renewed token %v", "s.c0ffee")
+
+	content, err := os.ReadFile(file)
+	assert.Nil(t, err)
+
+	var entry map[string]interface{}
+	err = json.Unmarshal(content, &entry)
+	assert.Nil(t, err, "Expected a single JSON log entry: %s", content)
+
+	assert.Equal(t, "abc-123", entry["request_id"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "Renewed token [REDACTED]", entry["msg"])
+	assert.NotEmpty(t, entry["src"])
+}
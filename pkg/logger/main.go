@@ -4,6 +4,12 @@ import (
 	log "github.com/sirupsen/logrus"
 	"os"
 	path "path/filepath"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
 var (
@@ -12,11 +18,7 @@ var (
 
 func init() {
 	log.SetLevel(log.InfoLevel)
-	log.SetFormatter(&log.TextFormatter{
-		TimestampFormat:  "01/02/2006 15:04:05.000000 -0700",
-		FullTimestamp:    true,
-		QuoteEmptyFields: true,
-	})
+	log.SetFormatter(textFormatter())
 }
 
 func SetLoggingLevel(level string) {
@@ -28,57 +30,53 @@ func SetLoggingLevel(level string) {
 	log.SetLevel(logLevel)
 }
 
+func SetLoggingFormat(format string) {
+	switch format {
+	case FormatText:
+		log.SetFormatter(textFormatter())
+	case FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		})
+	default:
+		log.Fatalf("Could not parse --log-format string '%v', expected one of: %v, %v", format, FormatText, FormatJSON)
+	}
+}
+
+// Appends log entries to the file instead of writing them to STDERR
+func SetLoggingFile(file string) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		log.Fatalf("Could not open --log-file '%v': %v", file, err)
+	}
+
+	log.SetOutput(f)
+}
+
+func textFormatter() log.Formatter {
+	return &log.TextFormatter{
+		TimestampFormat:  "01/02/2006 15:04:05.000000 -0700",
+		FullTimestamp:    true,
+		QuoteEmptyFields: true,
+	}
+}
+
 func Debugf(format string, args ...interface{}) {
-	log.WithFields(
-		log.Fields{
-			"src": filename,
-		},
-	).Debugf(
-		format,
-		args...,
-	)
+	WithFields(nil).Debugf(format, args...)
 }
 
 func Infof(format string, args ...interface{}) {
-	log.WithFields(
-		log.Fields{
-			"src": filename,
-		},
-	).Infof(
-		format,
-		args...,
-	)
+	WithFields(nil).Infof(format, args...)
 }
 
 func Warnf(format string, args ...interface{}) {
-	log.WithFields(
-		log.Fields{
-			"src": filename,
-		},
-	).Warnf(
-		format,
-		args...,
-	)
+	WithFields(nil).Warnf(format, args...)
 }
 
 func Errorf(format string, args ...interface{}) {
-	log.WithFields(
-		log.Fields{
-			"src": filename,
-		},
-	).Errorf(
-		format,
-		args...,
-	)
+	WithFields(nil).Errorf(format, args...)
 }
 
 func Fatalf(format string, args ...interface{}) {
-	log.WithFields(
-		log.Fields{
-			"src": filename,
-		},
-	).Fatalf(
-		format,
-		args...,
-	)
+	WithFields(nil).Fatalf(format, args...)
 }
@@ -0,0 +1,167 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	RedactedValue = "[REDACTED]"
+)
+
+var (
+	// Keys whose values are masked wherever they appear in a JSON body
+	RedactedKeys = map[string]bool{
+		"client_token": true,
+		"accessor":     true,
+		"secret_id":    true,
+		"role_id":      true,
+		"token":        true,
+		"jwt":          true,
+		"private_key":  true,
+	}
+
+	// Headers whose values are masked when logging requests
+	RedactedHeaders = []string{"X-Vault-Token"}
+
+	// Values shorter than this are not registered, since a secret like '1', 'true' or 'admin' would otherwise mask that
+	// text in every message logged from then on
+	RedactMinLength = 6
+
+	// The most values masked at once. Past that, the values registered longest ago are dropped, so 'parse --watch' and
+	// 'serve' fetching secrets over and over keep masking the ones they fetched recently, not every one ever seen.
+	RedactMaxValues = 1024
+
+	// The values masked, with the order they were last registered in
+	redactedValues     = make(map[string]uint64)
+	redactedGeneration uint64
+	redactedReplacer   = strings.NewReplacer()
+	redactedMutex      sync.RWMutex
+)
+
+// Registers values, like fetched secrets or tokens, that are masked in every message logged from now on. Registering
+// a value again keeps it from being dropped once there are more than RedactMaxValues.
+func Redact(values ...string) {
+	redactedMutex.Lock()
+	defer redactedMutex.Unlock()
+
+	changed := false
+	for _, value := range values {
+		if len(value) < RedactMinLength {
+			continue
+		}
+
+		redactedGeneration++
+		if _, ok := redactedValues[value]; ! ok {
+			changed = true
+		}
+		redactedValues[value] = redactedGeneration
+	}
+
+	for len(redactedValues) > RedactMaxValues {
+		dropOldestRedactedValue()
+		changed = true
+	}
+
+	// Only rebuild the replacer when the values changed, re-registering the same secret every interval is cheap
+	if ! changed {
+		return
+	}
+
+	// Replace longer values first, so a value containing another one is masked as a whole
+	sorted := make([]string, 0, len(redactedValues))
+	for value := range redactedValues {
+		sorted = append(sorted, value)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, value := range sorted {
+		pairs = append(pairs, value, RedactedValue)
+	}
+
+	redactedReplacer = strings.NewReplacer(pairs...)
+}
+
+func dropOldestRedactedValue() {
+	oldest, oldestGeneration := "", uint64(0)
+	for value, generation := range redactedValues {
+		if oldest == "" || generation < oldestGeneration {
+			oldest, oldestGeneration = value, generation
+		}
+	}
+
+	delete(redactedValues, oldest)
+}
+
+// Masks every registered value in the message
+func RedactString(message string) string {
+	redactedMutex.RLock()
+	defer redactedMutex.RUnlock()
+
+	return redactedReplacer.Replace(message)
+}
+
+// Masks a vault JSON body: the values under 'data' (keeping its keys, so the structure is still logged), the values of
+// RedactedKeys, and every registered value. Everything else, like the request id, lease and warnings, is left alone.
+// Bodies that are not JSON only get the registered values masked.
+func RedactJSON(body []byte) string {
+	var parsed interface{}
+
+	err := json.Unmarshal(body, &parsed)
+	if err != nil {
+		return RedactString(string(body))
+	}
+
+	redacted, err := json.Marshal(redactJSONValue(parsed, false))
+	if err != nil {
+		return RedactString(string(body))
+	}
+
+	return RedactString(string(redacted))
+}
+
+// Returns a copy of the headers with the values of RedactedHeaders masked
+func RedactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	for _, header := range RedactedHeaders {
+		if redacted.Get(header) != "" {
+			redacted.Set(header, RedactedValue)
+		}
+	}
+
+	return redacted
+}
+
+func redactJSONValue(value interface{}, mask bool) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(typed))
+		for key, nested := range typed {
+			redacted[key] = redactJSONValue(nested, mask || key == "data" || RedactedKeys[key])
+		}
+
+		return redacted
+
+	case []interface{}:
+		redacted := make([]interface{}, len(typed))
+		for i, nested := range typed {
+			redacted[i] = redactJSONValue(nested, mask)
+		}
+
+		return redacted
+
+	case nil:
+		return nil
+
+	default:
+		if mask {
+			return RedactedValue
+		}
+
+		return value
+	}
+}
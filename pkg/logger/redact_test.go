@@ -0,0 +1,63 @@
+package logger_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+func TestRedactString(t *testing.T) {
+	logger.Redact("hunter2", "hunter22", "")
+
+	assert.Equal(t, "password is [REDACTED] or [REDACTED]", logger.RedactString("password is hunter2 or hunter22"))
+	assert.Equal(t, "nothing to see", logger.RedactString("nothing to see"))
+}
+
+func TestRedactMinLength(t *testing.T) {
+	logger.Redact("8443", "true")
+
+	assert.Equal(t, "listening on 8443, tls is true", logger.RedactString("listening on 8443, tls is true"), "Expected short values to be left alone")
+}
+
+func TestRedactMaxValues(t *testing.T) {
+	defer func(max int) { logger.RedactMaxValues = max }(logger.RedactMaxValues)
+	logger.RedactMaxValues = 2
+
+	logger.Redact("rotated-1")
+	logger.Redact("current-1")
+	logger.Redact("rotated-2")
+
+	// Registering a value again keeps it, the one registered longest ago is dropped instead
+	logger.Redact("current-1")
+	logger.Redact("rotated-3")
+
+	assert.Equal(t, "rotated-1 rotated-2 [REDACTED] [REDACTED]", logger.RedactString("rotated-1 rotated-2 current-1 rotated-3"))
+}
+
+func TestRedactJSON(t *testing.T) {
+	body := `{"request_id":"abc-123","lease_duration":60,"data":{"username":"kevin","nested":{"password":"bacon"}},"auth":{"client_token":"s.dead","policies":["default"]}}`
+	redacted := logger.RedactJSON([]byte(body))
+
+	assert.Contains(t, redacted, `"request_id":"abc-123"`)
+	assert.Contains(t, redacted, `"lease_duration":60`)
+	assert.Contains(t, redacted, `"username":"[REDACTED]"`)
+	assert.Contains(t, redacted, `"password":"[REDACTED]"`)
+	assert.Contains(t, redacted, `"client_token":"[REDACTED]"`)
+	assert.Contains(t, redacted, `"policies":["default"]`)
+	assert.NotContains(t, redacted, "kevin")
+	assert.NotContains(t, redacted, "bacon")
+	assert.NotContains(t, redacted, "s.dead")
+}
+
+func TestRedactHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("X-Vault-Token", "s.dead")
+	headers.Set("Content-Type", "application/json")
+
+	redacted := logger.RedactHeaders(headers)
+	assert.Equal(t, "[REDACTED]", redacted.Get("X-Vault-Token"))
+	assert.Equal(t, "application/json", redacted.Get("Content-Type"))
+	assert.Equal(t, "s.dead", headers.Get("X-Vault-Token"), "Expected RedactHeaders() to leave the original headers alone")
+}
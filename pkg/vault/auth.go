@@ -1,5 +1,16 @@
 package vault
 
+// The auth methods a Client can use to log in and create a token
+const (
+	AuthMethodApprole    = "approle"
+	AuthMethodKubernetes = "kubernetes"
+	AuthMethodCert       = "cert"
+)
+
+var (
+	AuthMethods = []string{AuthMethodApprole, AuthMethodKubernetes, AuthMethodCert}
+)
+
 type Auth struct {
 	ClientToken      string            `json:"client_token"`
 	Accessor         string            `json:"accessor"`
@@ -11,5 +22,7 @@ type Auth struct {
 	Renewable        bool              `json:"renewable"`
 	EntityID         string            `json:"entity_id"`
 	Approle          Approle
+	Kubernetes       Kubernetes
+	Cert             Cert
 	Token            Token
 }
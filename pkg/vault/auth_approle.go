@@ -1,11 +1,12 @@
 package vault
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-)
+	"strings"
 
-var (
-	AuthApproleLoginLocation = "/auth/approle/login"
+	"github.com/Indellient/vault-helper/pkg/logger"
 )
 
 type ApproleLoginInput struct {
@@ -17,10 +18,67 @@ type Approle struct {
 	*Response
 }
 
-func (i *Approle) Login(v *Client) *Approle {
-	response, err := v.client.NewRequest().SetContext(v.ctx).SetBody(&ApproleLoginInput{RoleId: v.RoleId, SecretId: v.SecretId}).SetResult(i).SetError(VaultClientErrors{}).Post(AuthApproleLoginLocation)
+func (i *Approle) Login(v *Client) (*Approle, error) {
+	response, err := v.newRequest(v.authNamespace()).SetBody(&ApproleLoginInput{RoleId: v.RoleId, SecretId: v.SecretId}).SetResult(i).SetError(VaultClientErrors{}).Post(v.authLoginLocation())
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// Exchanges v.WrappedSecretId for the secret id it wraps, which is kept in v.SecretId for logging in again later. The
+// wrapping token is looked up first, and refused unless it was created by the secret-id endpoint of an approle role on
+// our auth mount, so a wrapping token for anything else (or one handed to us by someone else) is never unwrapped.
+func (v *Client) unwrapSecretId() error {
+	if v.WrappedSecretId == "" {
+		return nil
+	}
+
+	logger.Redact(v.WrappedSecretId)
+
+	lookup, err := new(SystemWrapping).Lookup(v, v.WrappedSecretId)
+	if err != nil {
+		return err
+	}
+
+	if ! v.isApproleSecretIdPath(lookup.Data.CreationPath) {
+		return &ValidationError{Err: fmt.Errorf("The wrapped secret id was created at '%v', expected 'auth/%v/role/<role>/secret-id'", lookup.Data.CreationPath, v.authMount())}
+	}
+
+	unwrapped, err := new(SystemUnwrap).Unwrap(v, v.WrappedSecretId)
+	if err != nil {
+		return err
+	}
+
+	secretId, _ := unwrapped.Data["secret_id"].(string)
+	if secretId == "" {
+		return errors.New("Vault did not return a secret id when unwrapping the wrapped secret id")
+	}
+
+	logger.Redact(secretId)
+
+	v.SecretId = secretId
+	v.WrappedSecretId = ""
+	return nil
+}
+
+// Whether the creation path of a wrapping token is 'auth/<mount>/role/<role>/secret-id' for our auth mount, optionally
+// prefixed with the auth namespace
+func (v *Client) isApproleSecretIdPath(creationPath string) bool {
+	creationPath = strings.Trim(creationPath, "/")
+	if namespace := strings.Trim(v.authNamespace(), "/"); namespace != "" {
+		creationPath = strings.TrimPrefix(creationPath, namespace+"/")
+	}
 
-	v.checkResponseForErrors(response, err, http.StatusOK)
+	prefix := fmt.Sprintf("auth/%v/role/", v.authMount())
+	suffix := "/secret-id"
+	if ! strings.HasPrefix(creationPath, prefix) || ! strings.HasSuffix(creationPath, suffix) {
+		return false
+	}
 
-	return i
+	role := strings.TrimSuffix(strings.TrimPrefix(creationPath, prefix), suffix)
+	return role != "" && ! strings.Contains(role, "/")
 }
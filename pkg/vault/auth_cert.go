@@ -0,0 +1,26 @@
+package vault
+
+import (
+	"net/http"
+)
+
+type CertLoginInput struct {
+	Name string `json:"name,omitempty"`
+}
+
+type Cert struct {
+	*Response
+}
+
+// Logs in with the client certificate the transport presents (see TLSConfig), optionally for a specific cert role.
+// Without a role, vault tries every role the certificate matches.
+func (i *Cert) Login(v *Client) (*Cert, error) {
+	response, err := v.newRequest(v.authNamespace()).SetBody(&CertLoginInput{Name: v.Role}).SetResult(i).SetError(VaultClientErrors{}).Post(v.authLoginLocation())
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
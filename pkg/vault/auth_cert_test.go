@@ -0,0 +1,78 @@
+package vault_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	path "path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+// Writes a self-signed client certificate and its key to dir, returning their paths
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "host.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	encodedKey, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile := path.Join(dir, "client.pem")
+	keyFile := path.Join(dir, "client-key.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0644))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: encodedKey}), 0600))
+
+	return certFile, keyFile
+}
+
+func TestClient_CreateTokenCert(t *testing.T) {
+	server := &fakeVault{secrets: map[string]map[string]interface{}{}, requests: make(map[string]int)}
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.handle))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeClientCertificate(t, dir)
+
+	// Without a client certificate, validation fails before asking vault
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{Insecure: true})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	client.AuthMethod = vault.AuthMethodCert
+	_, err = client.CreateToken("", "")
+	assert.NotNil(t, err, "Expected CreateToken() to return error for cert auth without client certificate")
+
+	// With a client certificate on the transport, vault sees it and logs us in
+	client, err = vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{Insecure: true, ClientCert: certFile, ClientKey: keyFile})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil with client certificate: %v", err)
+
+	client.AuthMethod = vault.AuthMethodCert
+	client.Role = "web"
+	token, err := client.CreateToken("", "")
+	assert.Nil(t, err, "Expected CreateToken() to return nil for cert auth with client certificate: %v", err)
+	assert.Equal(t, "dead-c0de", token)
+}
@@ -0,0 +1,38 @@
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	DefaultKubernetesJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+type KubernetesLoginInput struct {
+	Role string `json:"role"`
+	JWT  string `json:"jwt"`
+}
+
+type Kubernetes struct {
+	*Response
+}
+
+// The service account token is read on every login, since projected tokens are rotated by the kubelet.
+func (i *Kubernetes) Login(v *Client) (*Kubernetes, error) {
+	jwt, err := os.ReadFile(v.kubernetesJWTPath())
+	if err != nil {
+		return nil, fmt.Errorf("Could not read service account token '%v': %w", v.kubernetesJWTPath(), err)
+	}
+
+	response, err := v.newRequest(v.authNamespace()).SetBody(&KubernetesLoginInput{Role: v.Role, JWT: strings.TrimSpace(string(jwt))}).SetResult(i).SetError(VaultClientErrors{}).Post(v.authLoginLocation())
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
@@ -0,0 +1,49 @@
+package vault_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_CreateTokenKubernetes(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+
+	jwtPath := path.Join(t.TempDir(), "token")
+	assert.Nil(t, os.WriteFile(jwtPath, []byte("eyJ.first.jwt\n"), 0600))
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	client.AuthMethod = vault.AuthMethodKubernetes
+	client.Role = "app"
+	client.JWTPath = jwtPath
+
+	// Logs in at the default mount with the role and the service account token, without its trailing newline
+	token, err := client.CreateToken("", "")
+	assert.Nil(t, err, "Expected CreateToken() to return nil for kubernetes auth: %v", err)
+	assert.Equal(t, "dead-c0de", token)
+	assert.Equal(t, 1, server.count("POST /v1/auth/kubernetes/login"), "Expected a login at auth/kubernetes")
+	assert.Equal(t, map[string]interface{}{"role": "app", "jwt": "eyJ.first.jwt"}, server.loginRequests()[0])
+
+	// The token is read again on every login, since the kubelet rotates it, and logs in at --auth-mount when given
+	assert.Nil(t, os.WriteFile(jwtPath, []byte("eyJ.second.jwt"), 0600))
+	client.AuthMount = "kubernetes-prod"
+
+	token, err = client.CreateToken("", "")
+	assert.Nil(t, err, "Expected CreateToken() to return nil for kubernetes auth at another mount: %v", err)
+	assert.Equal(t, "dead-c0de-2", token)
+	assert.Equal(t, 1, server.count("POST /v1/auth/kubernetes-prod/login"), "Expected a login at auth/kubernetes-prod")
+	assert.Equal(t, map[string]interface{}{"role": "app", "jwt": "eyJ.second.jwt"}, server.loginRequests()[1])
+
+	// A missing service account token fails before asking vault
+	assert.Nil(t, os.Remove(jwtPath))
+
+	_, err = client.CreateToken("", "")
+	assert.NotNil(t, err, "Expected CreateToken() to return error for a missing service account token")
+	assert.Equal(t, 2, len(server.loginRequests()), "Expected no login without a service account token")
+}
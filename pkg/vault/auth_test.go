@@ -0,0 +1,93 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_AuthMount(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// The auth method is mounted at its own name by default
+	_, err = client.CreateToken("dead-beef", "ea7-beef")
+	assert.Nil(t, err, "Expected CreateToken() to return nil: %v", err)
+	assert.Equal(t, 1, server.count("POST /v1/auth/approle/login"))
+
+	// Either the mount name or its full path can be given
+	for _, mount := range []string{"approle-ci", "auth/approle-ci", "/auth/approle-ci/"} {
+		client.AuthMount = mount
+		_, err = client.CreateToken("dead-beef", "ea7-beef")
+		assert.Nil(t, err, "Expected CreateToken() to return nil for mount '%v': %v", mount, err)
+	}
+	assert.Equal(t, 3, server.count("POST /v1/auth/approle-ci/login"))
+	assert.Equal(t, 1, server.count("POST /v1/auth/approle/login"))
+}
+
+func TestClient_CreateTokenWrappedSecretId(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+	server.wrapped = map[string]map[string]interface{}{
+		"wrap-beef": {"creation_path": "auth/approle/role/jenkins/secret-id", "secret_id": "ea7-beef"},
+		"wrap-c0de": {"creation_path": "sys/wrapping/wrap", "secret_id": "ea7-c0de"},
+	}
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// The secret id is unwrapped before logging in, and kept for logging in again
+	client.WrappedSecretId = "wrap-beef"
+	_, err = client.CreateToken("dead-beef", "")
+	assert.Nil(t, err, "Expected CreateToken() to return nil for wrapped secret id: %v", err)
+	assert.Equal(t, "ea7-beef", client.SecretId)
+	assert.Equal(t, "", client.WrappedSecretId)
+	assert.Equal(t, 1, server.count("POST /v1/sys/wrapping/unwrap"))
+
+	// A wrapping token can only be unwrapped once
+	client.WrappedSecretId = "wrap-beef"
+	_, err = client.CreateToken("dead-beef", "")
+	var responseError *vault.ResponseError
+	assert.True(t, errors.As(err, &responseError), "Expected CreateToken() to return ResponseError for an unwrapped token, got: %v", err)
+
+	// Wrapping tokens not created by the approle secret-id endpoint of our mount are never unwrapped
+	client.WrappedSecretId = "wrap-c0de"
+	_, err = client.CreateToken("dead-beef", "")
+	var validationError *vault.ValidationError
+	assert.True(t, errors.As(err, &validationError), "Expected CreateToken() to return ValidationError for wrong creation path, got: %v", err)
+
+	client.AuthMount = "approle-ci"
+	server.wrapped["wrap-f00d"] = map[string]interface{}{"creation_path": "auth/approle/role/jenkins/secret-id", "secret_id": "ea7-f00d"}
+	client.WrappedSecretId = "wrap-f00d"
+	_, err = client.CreateToken("dead-beef", "")
+	assert.True(t, errors.As(err, &validationError), "Expected CreateToken() to return ValidationError for another auth mount, got: %v", err)
+	assert.Equal(t, 1, server.count("POST /v1/sys/wrapping/unwrap"))
+}
+
+func TestClient_RemoveSecretIdFile(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	secretIdFile := path.Join(t.TempDir(), "secret-id")
+	assert.Nil(t, os.WriteFile(secretIdFile, []byte("ea7-beef\n"), 0600))
+
+	// The file is left alone when logging in fails
+	client.RemoveSecretIdFile = secretIdFile
+	_, err = client.CreateToken("dead-beef", "")
+	assert.NotNil(t, err, "Expected CreateToken() to return error for empty secret id")
+	assert.FileExists(t, secretIdFile)
+
+	// And removed once it got us a token
+	_, err = client.CreateToken("dead-beef", "ea7-beef")
+	assert.Nil(t, err, "Expected CreateToken() to return nil: %v", err)
+	assert.NoFileExists(t, secretIdFile)
+	assert.Equal(t, "", client.RemoveSecretIdFile)
+}
@@ -11,16 +11,19 @@ type Token struct {
 	*Response
 }
 
-func (i *Token) RenewSelf(v *Client) *Token {
-	response, err := v.client.NewRequest().SetContext(v.ctx).SetHeader("X-Vault-Token", v.Token).SetResult(i).SetError(VaultClientErrors{}).Post(AuthTokenRenewSelfLocation)
+func (i *Token) RenewSelf(v *Client) (*Token, error) {
+	response, err := v.newRequest(v.authNamespace()).SetHeader("X-Vault-Token", v.Token).SetResult(i).SetError(VaultClientErrors{}).Post(AuthTokenRenewSelfLocation)
 
-	v.checkResponseForErrors(response, err, http.StatusOK)
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
 
-	return i
+	return i, nil
 }
 
-func (i *Token) RevokeSelf(v *Client) {
-	response, err := v.client.NewRequest().SetContext(v.ctx).SetHeader("X-Vault-Token", v.Token).SetError(VaultClientErrors{}).Post(AuthTokenRevokeSelfLocation)
+func (i *Token) RevokeSelf(v *Client) error {
+	response, err := v.newRequest(v.authNamespace()).SetHeader("X-Vault-Token", v.Token).SetError(VaultClientErrors{}).Post(AuthTokenRevokeSelfLocation)
 
-	v.checkResponseForErrors(response, err, http.StatusNoContent)
+	return v.checkResponseForErrors(response, err, http.StatusNoContent)
 }
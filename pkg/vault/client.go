@@ -3,7 +3,7 @@ package vault
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"gopkg.in/resty.v1"
@@ -12,8 +12,8 @@ import (
 	"net/url"
 	"os"
 	path "path/filepath"
+	"sort"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/Indellient/vault-helper/pkg/logger"
@@ -24,36 +24,51 @@ var (
 	ResponseHeaderTimeout = 20
 	ExpectContinueTimeout = 10
 	KeepAlive             = 3
+	NamespaceHeader       = "X-Vault-Namespace"
 	LeftTemplateDelim     = `((`
 	RightTemplateDelim    = `))`
 )
 
 // A client represents a go-resty based HTTP client that interacts with the vault API
 type Client struct {
-	Address  string
-	RoleId   string
-	SecretId string
-	Token    string
-	Path     string
-	File     string
-	Selector string
-	Insecure bool
+	Address            string
+	Namespace          string
+	AuthNamespace      string
+	AuthMethod         string
+	AuthMount          string
+	RoleId             string
+	SecretId           string
+	WrappedSecretId    string
+	RemoveSecretIdFile string
+	Role               string
+	JWTPath            string
+	Token              string
+	RevokeLeasedToken  bool
+	Path               string
+	File               string
+	Out                string
+	OutMode            string
+	OutOwner           string
+	SocketMode         string
+	SocketOwner        string
+	ExecOnChange       string
+	EnvPrefix          string
+	EnvUpperCase       bool
+	Selector           string
+	Version            int
+	TLS                TLSConfig
 
 	SystemHealth SystemHealth
 	Auth         Auth
 	Secret       Secret
 
-	client *resty.Client
-	ctx    context.Context
-}
-
-// When vault emits errors, we marshal them to this struct so it's easier to print out
-type VaultClientErrors struct {
-	Errors []string `json:"errors"`
-}
-
-func (i *VaultClientErrors) Error() string {
-	return strings.Join(i.Errors, ", ")
+	client       *resty.Client
+	ctx          context.Context
+	tokenAuth    *Auth
+	leaseIds      []string
+	leasedSecrets map[string]*cachedSecret
+	certificates  map[string]*cachedSecret
+	ciphertexts   map[string]string
 }
 
 // Basic validation of the vault inputs for the URL
@@ -64,6 +79,12 @@ func (v *Client) Validate() error {
 		return err
 	}
 
+	// Validate the CA and client certificates can be loaded
+	_, err = v.TLS.Build()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -71,10 +92,17 @@ func (v *Client) Validate() error {
 // Note that we expect vault to be initialized, unsealed, and the active node to continue.
 func (v *Client) ExtendedValidate() error {
 	// Setup the resty client
-	v.Setup()
+	err := v.Setup()
+	if err != nil {
+		return err
+	}
 
 	// Validate that SystemHealth is okay, this vault instance is ready
-	v.SystemHealth.Reload(v)
+	_, err = v.SystemHealth.Reload(v)
+	if err != nil {
+		return err
+	}
+
 	if ! v.SystemHealth.Ready() {
 		if ! v.SystemHealth.GetInitialized() {
 			return errors.New("Expected vault to be initialized")
@@ -95,9 +123,15 @@ func (v *Client) ExtendedValidate() error {
 }
 
 // Sets up the go-resty client to interact with the vault API service. We do set some defaults for retry count/wait/max,
-// and our own custom HTTP.Transport so we can ignore self-signed SSL certs if required. We also add a few retry conditions
-// if vault is having issues or over-loaded.
-func (v *Client) Setup() {
+// and our own custom HTTP.Transport so we can verify the server with our own CA certificates (or ignore self-signed SSL
+// certs if required) and present a client certificate. We also add a few retry conditions if vault is having issues or
+// over-loaded.
+func (v *Client) Setup() error {
+	tlsConfig, err := v.TLS.Build()
+	if err != nil {
+		return err
+	}
+
 	resty.SetRetryCount(5)
 	resty.SetRetryWaitTime(3 * time.Second)
 	resty.SetRetryMaxWaitTime(30 * time.Second)
@@ -111,7 +145,7 @@ func (v *Client) Setup() {
 		TLSHandshakeTimeout:   time.Duration(int64(TLSHandshakeTimeout) * time.Second.Nanoseconds()),
 		ResponseHeaderTimeout: time.Duration(int64(ResponseHeaderTimeout) * time.Second.Nanoseconds()),
 		ExpectContinueTimeout: time.Duration(int64(ExpectContinueTimeout) * time.Second.Nanoseconds()),
-		TLSClientConfig:       &tls.Config{InsecureSkipVerify: v.Insecure},
+		TLSClientConfig:       tlsConfig,
 	})
 	v.client.SetHostURL(fmt.Sprintf("%v/v1", v.Address))
 	v.client.AddRetryCondition(resty.RetryConditionFunc(func(r *resty.Response) (bool, error) { return r.StatusCode() == http.StatusBadRequest, nil }))
@@ -119,24 +153,108 @@ func (v *Client) Setup() {
 	v.client.AddRetryCondition(resty.RetryConditionFunc(func(r *resty.Response) (bool, error) { return r.StatusCode() == http.StatusGatewayTimeout, nil }))
 	v.client.AddRetryCondition(resty.RetryConditionFunc(func(r *resty.Response) (bool, error) { return r.StatusCode() == http.StatusInternalServerError, nil }))
 	v.client.AddRetryCondition(resty.RetryConditionFunc(func(r *resty.Response) (bool, error) { return r.StatusCode() == http.StatusServiceUnavailable, nil }))
+
+	return nil
+}
+
+// Starts a new request to the vault API in the given namespace, or in the root namespace when it is empty
+func (v *Client) newRequest(namespace string) *resty.Request {
+	request := v.client.NewRequest().SetContext(v.ctx)
+	if namespace != "" {
+		request.SetHeader(NamespaceHeader, namespace)
+	}
+
+	return request
+}
+
+// Logging in and renewing or revoking the token happens in v.AuthNamespace when given, so a token can be created from
+// an auth method mounted in a parent namespace of v.Namespace
+func (v *Client) authNamespace() string {
+	if v.AuthNamespace == "" {
+		return v.Namespace
+	}
+
+	return v.AuthNamespace
 }
 
 // Once we make a request to the vault HTTP API, we always need to verify the response we recieved back from the server
 // is what we expected. This also can catch low-level HTTP responses as well (timeout, eof, connection refused) directly
 // on the responseError object.
-func (v *Client) checkResponseForErrors(response *resty.Response, responseError error, validStatusCodes ...int) {
-	// Log a debug message with the raw response
-	logger.Debugf("Response Body: %s", response.Body())
+func (v *Client) checkResponseForErrors(response *resty.Response, responseError error, validStatusCodes ...int) error {
+	// Log a debug message with the response, with secrets and tokens masked
+	if response != nil && response.Request != nil {
+		entry := logger.WithFields(v.responseFields(response))
+		entry.Debugf("Request headers: %v", logger.RedactHeaders(response.Request.Header))
+		entry.Debugf("Response body: %s", logger.RedactJSON(response.Body()))
+	}
 
 	// Check to make sure the response error object is nil--if it is not, may indicate a low-level HTTP error
 	if responseError != nil {
-		logger.Fatalf("Got low-level HTTP error: %v", responseError)
+		httpError := &HTTPError{Err: responseError}
+		if response != nil && response.Request != nil {
+			httpError.Method, httpError.Path, httpError.Duration = response.Request.Method, requestPath(response), response.Time()
+		}
+
+		return httpError
 	}
 
 	// Validate the response HTTP status code against validStatusCodes[]
 	if ! v.contains(response.StatusCode(), validStatusCodes) {
-		logger.Fatalf("Response %v was not one of %v: %v", response.StatusCode(), validStatusCodes, response.Error())
+		vaultErrors, _ := response.Error().(*VaultClientErrors)
+
+		return &ResponseError{
+			Method:           response.Request.Method,
+			Path:             requestPath(response),
+			RequestId:        responseRequestId(response),
+			Duration:         response.Time(),
+			StatusCode:       response.StatusCode(),
+			ValidStatusCodes: validStatusCodes,
+			Errors:           vaultErrors,
+		}
 	}
+
+	return nil
+}
+
+// The fields describing a request and its response in the logs, like the vault request id, path and HTTP status
+func (v *Client) responseFields(response *resty.Response) logger.Fields {
+	fields := logger.Fields{
+		"method":   response.Request.Method,
+		"path":     requestPath(response),
+		"status":   response.StatusCode(),
+		"duration": response.Time().String(),
+	}
+
+	if namespace := response.Request.Header.Get(NamespaceHeader); namespace != "" {
+		fields["namespace"] = namespace
+	}
+
+	if requestId := responseRequestId(response); requestId != "" {
+		fields["request_id"] = requestId
+	}
+
+	return fields
+}
+
+// The path a request was made to, like '/v1/secret/data/db', without the address of vault or the query
+func requestPath(response *resty.Response) string {
+	if response.Request.RawRequest != nil {
+		return response.Request.RawRequest.URL.Path
+	}
+
+	return response.Request.URL
+}
+
+// The vault request id in the body of a response, which is empty when the body has none
+func responseRequestId(response *resty.Response) string {
+	var body struct {
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(response.Body(), &body) != nil {
+		return ""
+	}
+
+	return body.RequestID
 }
 
 // Silly struct method to determine if expected is contained in items.
@@ -150,43 +268,191 @@ func (v *Client) contains(expected int, items []int) bool {
 	return false
 }
 
-// Given the role id and secret id,
-func (v *Client) CreateToken(roleId, secretId string) string {
+// Given the role id and secret id (or the role and service account token for kubernetes, or the client certificate for
+// cert), log in with the configured auth method and return the new client token
+func (v *Client) CreateToken(roleId, secretId string) (string, error) {
 	v.RoleId = roleId
 	v.SecretId = secretId
 
 	err := v.ValidateCreateToken()
 	if err != nil {
-		logger.Fatalf("%v", err)
+		return "", &ValidationError{Err: err}
 	}
 
-	v.Token = v.Auth.Approle.Login(v).Auth.ClientToken
-	return v.Token
+	err = v.login()
+	if err != nil {
+		return "", err
+	}
+
+	return v.Token, nil
 }
 
 func (v *Client) ValidateCreateToken() error {
-	// Make sure role id is non-empty
-	if v.RoleId == "" {
-		return errors.New("Role ID cannot be empty")
+	return v.ValidateLogin()
+}
+
+// Logs in to vault with the configured auth method, storing the new client token on the Client
+func (v *Client) login() error {
+	var auth *Auth
+
+	logger.Redact(v.RoleId, v.SecretId)
+
+	switch v.authMethod() {
+	case AuthMethodKubernetes:
+		kubernetes, err := v.Auth.Kubernetes.Login(v)
+		if err != nil {
+			return err
+		}
+
+		auth = kubernetes.Auth
+	case AuthMethodCert:
+		cert, err := v.Auth.Cert.Login(v)
+		if err != nil {
+			return err
+		}
+
+		auth = cert.Auth
+	default:
+		err := v.unwrapSecretId()
+		if err != nil {
+			return err
+		}
+
+		approle, err := v.Auth.Approle.Login(v)
+		if err != nil {
+			return err
+		}
+
+		auth = approle.Auth
+	}
+
+	if auth == nil || auth.ClientToken == "" {
+		return fmt.Errorf("Vault did not return a client token when logging in with %v", v.authMethod())
 	}
 
-	// Make sure secret id is non-empty
-	if v.SecretId == "" {
-		return errors.New("Secret ID cannot be empty")
+	// The accessor is left readable in messages, it is how a token left to expire is told apart
+	logger.Redact(auth.ClientToken)
+
+	v.Token = auth.ClientToken
+	v.tokenAuth = auth
+
+	v.removeSecretIdFile()
+	return nil
+}
+
+// Removes the file the secret id was read from once it got us a token, the same as vault agent's approle auto-auth. We
+// keep the secret id in memory to log in again, so this only ever happens after the first login.
+func (v *Client) removeSecretIdFile() {
+	if v.RemoveSecretIdFile == "" {
+		return
+	}
+
+	err := os.Remove(v.RemoveSecretIdFile)
+	if err != nil && ! os.IsNotExist(err) {
+		logger.Warnf("Could not remove secret id file %v: %v", v.RemoveSecretIdFile, err)
+	} else {
+		logger.Infof("Removed secret id file %v", v.RemoveSecretIdFile)
+	}
+
+	v.RemoveSecretIdFile = ""
+}
+
+// Validates the inputs required by the configured auth method
+func (v *Client) ValidateLogin() error {
+	// Make sure a wrapped secret id is not silently ignored by the other auth methods
+	if v.WrappedSecretId != "" && v.authMethod() != AuthMethodApprole {
+		return fmt.Errorf("A wrapped secret id can only be used with the %v auth method", AuthMethodApprole)
+	}
+
+	switch v.authMethod() {
+	case AuthMethodApprole:
+		// Make sure role id is non-empty
+		if v.RoleId == "" {
+			return errors.New("Role ID cannot be empty")
+		}
+
+		// Make sure exactly one of secret id and wrapped secret id is non-empty
+		if v.SecretId == "" && v.WrappedSecretId == "" {
+			return errors.New("Secret ID cannot be empty")
+		}
+
+		if v.SecretId != "" && v.WrappedSecretId != "" {
+			return errors.New("Only one of secret id and wrapped secret id can be given")
+		}
+
+	case AuthMethodKubernetes:
+		// Make sure role is non-empty
+		if v.Role == "" {
+			return errors.New("Role cannot be empty")
+		}
+
+		// Make sure the service account token is accessible
+		if _, err := os.Stat(v.kubernetesJWTPath()); err != nil {
+			return fmt.Errorf("The service account token %v either does not exist or cannot be accessed: %v", v.kubernetesJWTPath(), err)
+		}
+
+	case AuthMethodCert:
+		// Make sure the transport presents a client certificate, the role is optional
+		if v.TLS.ClientCert == "" || v.TLS.ClientKey == "" {
+			return errors.New("Client certificate and client key are required to log in with the cert auth method")
+		}
+
+	default:
+		return fmt.Errorf("Unknown auth method '%v', expected one of: %v", v.AuthMethod, strings.Join(AuthMethods, ", "))
 	}
 
 	return nil
 }
 
-func (v *Client) RenewToken(token string) string {
+// The auth method defaults to approle when none is set
+func (v *Client) authMethod() string {
+	if v.AuthMethod == "" {
+		return AuthMethodApprole
+	}
+
+	return v.AuthMethod
+}
+
+// The auth method is mounted at auth/<method> unless v.AuthMount says otherwise, like 'approle-ci' or 'auth/approle-ci'
+func (v *Client) authMount() string {
+	mount := strings.Trim(v.AuthMount, "/")
+	mount = strings.TrimPrefix(mount, "auth/")
+	if mount == "" {
+		return v.authMethod()
+	}
+
+	return mount
+}
+
+// The location every auth method logs in at, below its mount
+func (v *Client) authLoginLocation() string {
+	return fmt.Sprintf("/auth/%v/login", v.authMount())
+}
+
+// The service account token path defaults to where kubernetes mounts it in the pod
+func (v *Client) kubernetesJWTPath() string {
+	if v.JWTPath == "" {
+		return DefaultKubernetesJWTPath
+	}
+
+	return v.JWTPath
+}
+
+func (v *Client) RenewToken(token string) (string, error) {
 	v.Token = token
+	logger.Redact(v.Token)
 
 	err := v.ValidateRenewToken()
 	if err != nil {
-		logger.Fatalf("%v", err)
+		return "", &ValidationError{Err: err}
 	}
 
-	return v.Auth.Token.RenewSelf(v).Auth.ClientToken
+	renewed, err := v.Auth.Token.RenewSelf(v)
+	if err != nil {
+		return "", err
+	}
+
+	return renewed.Auth.ClientToken, nil
 }
 
 func (v *Client) ValidateRenewToken() error {
@@ -198,17 +464,22 @@ func (v *Client) ValidateRenewToken() error {
 	return nil
 }
 
-func (v *Client) RevokeToken(token string) {
+func (v *Client) RevokeToken(token string) error {
 	v.Token = token
+	logger.Redact(v.Token)
 
 	err := v.ValidateRevokeToken()
 	if err != nil {
-		logger.Fatalf("%v", err)
+		return &ValidationError{Err: err}
 	}
 
-	v.Auth.Token.RevokeSelf(v)
+	err = v.Auth.Token.RevokeSelf(v)
+	if err != nil {
+		return err
+	}
 
 	logger.Infof("Token revoked successfully!")
+	return nil
 }
 
 func (v *Client) ValidateRevokeToken() error {
@@ -220,30 +491,35 @@ func (v *Client) ValidateRevokeToken() error {
 	return nil
 }
 
-func (v *Client) FetchSecret(token, path, selector string) string {
+func (v *Client) FetchSecret(token, path, selector string) (string, error) {
 	v.Token = token
+	logger.Redact(v.Token)
 	v.Path = path
 	v.Selector = selector
 
 	err := v.ValidateFetchSecret()
 	if err != nil {
-		logger.Fatalf("%v", err)
+		return "", &ValidationError{Err: err}
+	}
+
+	secret, err := v.Secret.Get(v)
+	if err != nil {
+		return "", err
 	}
 
-	secrets := v.Secret.Get(v).Data
 	var parsed bytes.Buffer
 
-	template, err := template.New("secrets").Delims(LeftTemplateDelim, RightTemplateDelim).Parse(v.Selector)
+	template, err := v.newTemplate("secrets").Parse(v.Selector)
 	if err != nil {
-		logger.Fatalf("Could not parse template selector '%v': %v", v.Selector, err)
+		return "", &TemplateError{Template: v.Selector, Err: err}
 	}
 
-	err = template.Execute(&parsed, secrets)
+	err = template.Execute(&parsed, secret.Data)
 	if err != nil {
-		logger.Fatalf("Could not render template selector '%v': %v", v.Selector, err)
+		return "", &TemplateError{Template: v.Selector, Err: err}
 	}
 
-	return parsed.String()
+	return parsed.String(), nil
 }
 
 func (v *Client) ValidateFetchSecret() error {
@@ -262,8 +538,13 @@ func (v *Client) ValidateFetchSecret() error {
 		return errors.New("Selector cannot be empty")
 	}
 
+	// Make sure version is either unset or a valid version number
+	if v.Version < 0 {
+		return errors.New("Version cannot be negative")
+	}
+
 	// Initialize and attempt to parse the token replacement
-	_, err := template.New("secrets").Delims(LeftTemplateDelim, RightTemplateDelim).Parse(v.Selector)
+	_, err := v.newTemplate("secrets").Parse(v.Selector)
 	if err != nil {
 		return fmt.Errorf("Could not parse template selector '%v': %v", v.Selector, err)
 	}
@@ -271,7 +552,158 @@ func (v *Client) ValidateFetchSecret() error {
 	return nil
 }
 
-func (v *Client) ParseFile(roleId, secretId, vaultPath, file string) {
+// Given the token, writes data to the secret at path, replacing the keys it had. When cas is not negative, a KV v2
+// secret is only written if cas is its current version, 0 meaning it must not exist yet. Returns the version written,
+// which is 0 for KV v1 secrets.
+func (v *Client) PutSecret(token, path string, data map[string]interface{}, cas int) (int, error) {
+	v.Token = token
+	logger.Redact(v.Token)
+	v.Path = path
+
+	err := v.ValidateWriteSecret(data)
+	if err != nil {
+		return 0, &ValidationError{Err: err}
+	}
+
+	secret, err := new(Secret).Put(v, v.Path, data, cas)
+	if err != nil {
+		return 0, err
+	}
+
+	return secret.CurrentVersion(), nil
+}
+
+// Same as PutSecret, merging data in to the keys the secret already has. Unless cas is given, a KV v2 secret is written
+// with the version that was read as check-and-set, so a write made in between fails rather than being lost.
+func (v *Client) PatchSecret(token, path string, data map[string]interface{}, cas int) (int, error) {
+	v.Token = token
+	logger.Redact(v.Token)
+	v.Path = path
+
+	err := v.ValidateWriteSecret(data)
+	if err != nil {
+		return 0, &ValidationError{Err: err}
+	}
+
+	current, err := new(Secret).GetPath(v, v.Path, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	merged := make(map[string]interface{}, len(current.Data)+len(data))
+	for key, value := range current.Data {
+		merged[key] = value
+	}
+	for key, value := range data {
+		merged[key] = value
+	}
+
+	if cas < 0 && current.CurrentVersion() > 0 {
+		cas = current.CurrentVersion()
+	}
+
+	secret, err := new(Secret).Put(v, v.Path, merged, cas)
+	if err != nil {
+		return 0, err
+	}
+
+	return secret.CurrentVersion(), nil
+}
+
+func (v *Client) ValidateWriteSecret(data map[string]interface{}) error {
+	// Make sure token is non-empty
+	if v.Token == "" {
+		return errors.New("Token cannot be empty")
+	}
+
+	// Make sure path is non-empty
+	if v.Path == "" {
+		return errors.New("Path cannot be empty")
+	}
+
+	// Make sure there is something to write
+	if len(data) == 0 {
+		return errors.New("Data cannot be empty")
+	}
+
+	// Make sure every key is non-empty
+	for key := range data {
+		if key == "" {
+			return errors.New("Keys cannot be empty")
+		}
+	}
+
+	return nil
+}
+
+// Given the token, lists the keys under path, sorted, where keys ending in '/' hold more keys. When recursive, those
+// are walked as well, and only the keys of secrets are returned, like 'dev/user/admin'.
+func (v *Client) ListSecrets(token, path string, recursive bool) ([]string, error) {
+	v.Token = token
+	logger.Redact(v.Token)
+	v.Path = path
+
+	err := v.ValidateListSecrets()
+	if err != nil {
+		return nil, &ValidationError{Err: err}
+	}
+
+	root := strings.TrimSuffix(v.Path, "/") + "/"
+
+	mount, err := v.secretMount(root)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := v.listSecrets(mount, root, "", recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Lists the keys under root+prefix, returning them relative to root
+func (v *Client) listSecrets(mount *SystemMount, root, prefix string, recursive bool) ([]string, error) {
+	keys, err := new(SecretList).list(v, mount, root+prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var listed []string
+	for _, key := range keys {
+		if ! recursive || ! strings.HasSuffix(key, "/") {
+			listed = append(listed, prefix+key)
+			continue
+		}
+
+		nested, err := v.listSecrets(mount, root, prefix+key, recursive)
+		if err != nil {
+			return nil, err
+		}
+
+		listed = append(listed, nested...)
+	}
+
+	return listed, nil
+}
+
+func (v *Client) ValidateListSecrets() error {
+	// Make sure token is non-empty
+	if v.Token == "" {
+		return errors.New("Token cannot be empty")
+	}
+
+	// Make sure path is non-empty
+	if strings.Trim(v.Path, "/") == "" {
+		return errors.New("Path cannot be empty")
+	}
+
+	return nil
+}
+
+func (v *Client) ParseFile(roleId, secretId, vaultPath, file string) error {
 	// Set vars for parsing the file
 	v.RoleId = roleId
 	v.SecretId = secretId
@@ -280,48 +712,155 @@ func (v *Client) ParseFile(roleId, secretId, vaultPath, file string) {
 
 	err := v.ValidateParseFile()
 	if err != nil {
-		logger.Fatalf("%v", err)
+		return &ValidationError{Err: err}
 	}
 
 	// Create the token
-	v.Token = v.Auth.Approle.Login(v).Auth.ClientToken
+	err = v.login()
+	if err != nil {
+		return err
+	}
+
+	err = v.renderFile()
+	if err != nil {
+		// Do not leave the token behind when we could not finish parsing the file
+		v.revokeToken()
+
+		return err
+	}
+
+	// Revoke the token, unless that would revoke the dynamic secrets we just rendered
+	revoked, err := v.revokeTokenUnlessLeased()
+	if err != nil {
+		return err
+	}
+
+	if revoked {
+		logger.Infof("Successfully parsed secrets from %v to file %v and auto-revoked token!", v.Path, v.outputFile())
+	} else {
+		logger.Infof("Successfully parsed secrets from %v to file %v, the token expires with their leases", v.Path, v.outputFile())
+	}
+
+	return nil
+}
+
+// Fetches the secret at v.Path and renders v.File with it, using the current token, then writes the result to the
+// output file. Nothing is written unless the whole template rendered successfully. When the content differs from what
+// was in the output file before, v.ExecOnChange is run.
+func (v *Client) renderFile() error {
+	content, err := v.render()
+	if err != nil {
+		return err
+	}
 
+	changed := v.outputChanged(content)
+
+	err = v.writeFile(content)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		return v.execOnChange()
+	}
+
+	return nil
+}
+
+// Whether the rendered content differs from what is in the output file, which includes the output file not existing
+func (v *Client) outputChanged(content []byte) bool {
+	previous, err := os.ReadFile(v.outputFile())
+
+	return err != nil || ! bytes.Equal(previous, content)
+}
+
+// Fetches the secret at v.Path and renders v.File with it in memory
+func (v *Client) render() ([]byte, error) {
 	// Fetch secret data
-	secrets := v.Secret.Get(v).Data
+	secret, err := v.Secret.Get(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.renderSecret(secret)
+}
 
+// Same as render, with the secret at v.Path that was already fetched
+func (v *Client) renderSecret(secret *Secret) ([]byte, error) {
 	// Parse the file contents
-	template, err := template.New(path.Base(v.File)).Delims(LeftTemplateDelim, RightTemplateDelim).ParseFiles(v.File)
+	template, err := v.newTemplate(path.Base(v.File)).ParseFiles(v.File)
 	if err != nil {
-		logger.Fatalf("Could not parse template file '%v': %v", v.File, err)
+		return nil, &TemplateError{Template: v.File, Err: err}
 	}
 
-	// Create the new file we will write content to
-	f, err := os.Create(v.File)
+	// Render the parsed file contents
+	var parsed bytes.Buffer
+
+	err = template.Execute(&parsed, secret.Data)
 	if err != nil {
-		logger.Fatalf("Could not create file '%v': %v", v.File, err)
+		return nil, &TemplateError{Template: v.File, Err: err}
 	}
 
-	// Write parsed file contents to disk
-	err = template.Execute(f, secrets)
+	return parsed.Bytes(), nil
+}
+
+// Writes the rendered content to the output file atomically, so a crash or a failed write never leaves the output file
+// empty or half-written. The mode and owner are v.OutMode and v.OutOwner when given, otherwise those of the output file
+// being replaced are kept.
+func (v *Client) writeFile(content []byte) error {
+	out := v.outputFile()
+
+	mode, err := v.outputMode()
 	if err != nil {
-		logger.Fatalf("Could not render parsed template content '%v' to disk: %v", v.File, err)
+		return err
 	}
 
-	// Revoke the token
-	v.Auth.Token.RevokeSelf(v)
+	uid, gid := -1, -1
+	if v.OutOwner != "" {
+		uid, gid, err = lookupOwner(v.OutOwner)
+		if err != nil {
+			return err
+		}
+	} else if info, err := os.Stat(out); err == nil {
+		uid, gid = fileOwner(info)
+	}
 
-	logger.Infof("Successfully parsed secrets from %v to file %v and auto-revoked token!", v.Path, v.File)
+	return writeFileAtomic(out, content, mode, uid, gid, v.OutOwner != "")
 }
 
-func (v *Client) ValidateParseFile() error {
-	// Make sure role id is non-empty
-	if v.RoleId == "" {
-		return errors.New("Role ID cannot be empty")
+// The rendered file is written to v.Out when given, otherwise the template file is overwritten in place
+func (v *Client) outputFile() string {
+	if v.Out == "" {
+		return v.File
+	}
+
+	return v.Out
+}
+
+// The mode of the rendered file is v.OutMode when given, otherwise the mode of the output file being replaced, or the
+// mode of the template file when there is no output file yet
+func (v *Client) outputMode() (os.FileMode, error) {
+	if v.OutMode != "" {
+		return parseFileMode(v.OutMode)
+	}
+
+	if info, err := os.Stat(v.outputFile()); err == nil {
+		return info.Mode().Perm(), nil
 	}
 
-	// Make sure secret id is non-empty
-	if v.SecretId == "" {
-		return errors.New("Secret ID cannot be empty")
+	info, err := os.Stat(v.File)
+	if err != nil {
+		return 0, fmt.Errorf("Could not stat file '%v': %w", v.File, err)
+	}
+
+	return info.Mode().Perm(), nil
+}
+
+func (v *Client) ValidateParseFile() error {
+	// Make sure we have what we need to log in
+	err := v.ValidateLogin()
+	if err != nil {
+		return err
 	}
 
 	// Make sure path is non-empty
@@ -329,10 +868,29 @@ func (v *Client) ValidateParseFile() error {
 		return errors.New("Path cannot be empty")
 	}
 
+	// Make sure version is either unset or a valid version number
+	if v.Version < 0 {
+		return errors.New("Version cannot be negative")
+	}
+
 	// Make sure file is non-empty and accessible
 	if _, err := os.Stat(v.File); os.IsNotExist(err) {
 		return fmt.Errorf("The file to parse %v either does not exist or cannot be accessed: %v", v.File, err)
 	}
 
+	// Make sure output mode is a valid octal file mode
+	if v.OutMode != "" {
+		if _, err := parseFileMode(v.OutMode); err != nil {
+			return err
+		}
+	}
+
+	// Make sure output owner is a known user (and group)
+	if v.OutOwner != "" {
+		if _, _, err := lookupOwner(v.OutOwner); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -1,6 +1,8 @@
 package vault_test
 
 import (
+	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
 
@@ -151,3 +153,177 @@ func TestClient_ValidateParseFile(t *testing.T) {
 	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
 	assert.Nil(t, client.ValidateParseFile(), "Expected ValidateParseFile() to return nil for valid role id, secret id, path, and file: %v", client.ValidateParseFile())
 }
+
+func TestClient_ValidationErrors(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+	var validationError *vault.ValidationError
+
+	// Invalid input is reported as a ValidationError before any request is made to vault
+	client = Setup("https://google.com", "", "", "", "", "", "")
+	_, err := client.CreateToken("", "")
+	assert.True(t, errors.As(err, &validationError), "Expected CreateToken() to return a ValidationError for empty role and secret id: %v", err)
+
+	_, err = client.RenewToken("")
+	assert.True(t, errors.As(err, &validationError), "Expected RenewToken() to return a ValidationError for empty token: %v", err)
+
+	err = client.RevokeToken("")
+	assert.True(t, errors.As(err, &validationError), "Expected RevokeToken() to return a ValidationError for empty token: %v", err)
+
+	_, err = client.FetchSecret("dead-c0de", "/foo/bar", "((.username")
+	assert.True(t, errors.As(err, &validationError), "Expected FetchSecret() to return a ValidationError for invalid selector: %v", err)
+
+	err = client.ParseFile("dead-beef", "ea7-beef", "/foo/bar", "foobar.groovy")
+	assert.True(t, errors.As(err, &validationError), "Expected ParseFile() to return a ValidationError for invalid file: %v", err)
+
+	// Invalid address is reported as a ValidationError by the constructor
+	_, err = vault.NewVaultClient(context.Background(), "google.com", vault.TLSConfig{})
+	assert.True(t, errors.As(err, &validationError), "Expected NewVaultClient() to return a ValidationError for invalid address: %v", err)
+}
+
+func TestClient_RequestErrors(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// Vault refusing a request is reported with the request it came from
+	var responseError *vault.ResponseError
+	_, err = client.FetchSecret("dead-c0de", "kv/missing", "((.username))")
+	assert.True(t, errors.As(err, &responseError), "Expected FetchSecret() to return a ResponseError for a missing secret: %v", err)
+	assert.Equal(t, "GET", responseError.Method)
+	assert.Equal(t, "/v1/kv/missing", responseError.Path)
+	assert.Equal(t, 404, responseError.StatusCode)
+	assert.NotEqual(t, "", responseError.RequestId, "Expected the request id of the response")
+	assert.Greater(t, int64(responseError.Duration), int64(0))
+
+	// So is vault not answering at all
+	var httpError *vault.HTTPError
+	server.Close()
+	_, err = client.FetchSecret("dead-c0de", "kv/missing", "((.username))")
+	assert.True(t, errors.As(err, &httpError), "Expected FetchSecret() to return an HTTPError for a stopped vault: %v", err)
+	assert.Equal(t, "GET", httpError.Method)
+	assert.Contains(t, httpError.Path, "/kv/missing")
+}
+
+func TestClient_ValidateLogin(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Unknown auth method
+	client = &vault.Client{Address: "https://google.com", AuthMethod: "userpass"}
+	assert.NotNil(t, client.ValidateLogin(), "Expected ValidateLogin() to return error for unknown auth method 'userpass'")
+
+	// Approle is the default auth method
+	client = &vault.Client{Address: "https://google.com", RoleId: "dead-beef"}
+	assert.NotNil(t, client.ValidateLogin(), "Expected ValidateLogin() to return error for empty secret id with default auth method")
+
+	// A wrapped secret id stands in for the secret id, but not next to it
+	client = &vault.Client{Address: "https://google.com", RoleId: "dead-beef", WrappedSecretId: "wrap-beef"}
+	assert.Nil(t, client.ValidateLogin(), "Expected ValidateLogin() to return nil for wrapped secret id: %v", client.ValidateLogin())
+
+	client = &vault.Client{Address: "https://google.com", RoleId: "dead-beef", SecretId: "ea7-beef", WrappedSecretId: "wrap-beef"}
+	assert.NotNil(t, client.ValidateLogin(), "Expected ValidateLogin() to return error for both secret id and wrapped secret id")
+
+	client = &vault.Client{Address: "https://google.com", AuthMethod: vault.AuthMethodKubernetes, Role: "jenkins", JWTPath: "example.groovy", WrappedSecretId: "wrap-beef"}
+	assert.NotNil(t, client.ValidateLogin(), "Expected ValidateLogin() to return error for wrapped secret id with kubernetes auth")
+
+	// Missing kubernetes role
+	client = &vault.Client{Address: "https://google.com", AuthMethod: vault.AuthMethodKubernetes, JWTPath: "example.groovy"}
+	assert.NotNil(t, client.ValidateLogin(), "Expected ValidateLogin() to return error for empty kubernetes role")
+
+	// Invalid service account token path
+	client = &vault.Client{Address: "https://google.com", AuthMethod: vault.AuthMethodKubernetes, Role: "jenkins", JWTPath: "foobar.jwt"}
+	assert.NotNil(t, client.ValidateLogin(), "Expected ValidateLogin() to return error for invalid service account token 'foobar.jwt'")
+
+	// Missing client certificate for cert auth
+	client = &vault.Client{Address: "https://google.com", AuthMethod: vault.AuthMethodCert, Role: "web"}
+	assert.NotNil(t, client.ValidateLogin(), "Expected ValidateLogin() to return error for cert auth without client certificate")
+
+	// Valid client certificate for cert auth, the role is optional
+	client = &vault.Client{Address: "https://google.com", AuthMethod: vault.AuthMethodCert, TLS: vault.TLSConfig{ClientCert: "cert.pem", ClientKey: "key.pem"}}
+	assert.Nil(t, client.ValidateLogin(), "Expected ValidateLogin() to return nil for cert auth with client certificate: %v", client.ValidateLogin())
+
+	// Valid kubernetes role and service account token path, no approle role id or secret id required
+	client = &vault.Client{Address: "https://google.com", AuthMethod: vault.AuthMethodKubernetes, Role: "jenkins", JWTPath: "example.groovy"}
+	assert.Nil(t, client.ValidateLogin(), "Expected ValidateLogin() to return nil for valid kubernetes role and service account token: %v", client.ValidateLogin())
+}
+
+func TestClient_ValidateVersion(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Negative version when fetching a secret
+	client = Setup("https://google.com", "", "", "dead-c0de", "/foo/bar", "", "((.username))")
+	client.Version = -1
+	assert.NotNil(t, client.ValidateFetchSecret(), "Expected ValidateFetchSecret() to return error for negative version")
+
+	// Negative version when parsing a file
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
+	client.Version = -1
+	assert.NotNil(t, client.ValidateParseFile(), "Expected ValidateParseFile() to return error for negative version")
+
+	// Valid version when fetching a secret
+	client = Setup("https://google.com", "", "", "dead-c0de", "/foo/bar", "", "((.username))")
+	client.Version = 3
+	assert.Nil(t, client.ValidateFetchSecret(), "Expected ValidateFetchSecret() to return nil for version 3: %v", client.ValidateFetchSecret())
+
+	// Valid version when parsing a file
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
+	client.Version = 3
+	assert.Nil(t, client.ValidateParseFile(), "Expected ValidateParseFile() to return nil for version 3: %v", client.ValidateParseFile())
+}
+
+func TestClient_ValidateParseFileOutput(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Invalid output mode
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
+	client.OutMode = "rw-r-----"
+	assert.NotNil(t, client.ValidateParseFile(), "Expected ValidateParseFile() to return error for invalid output mode 'rw-r-----'")
+
+	// Output mode out of range
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
+	client.OutMode = "17777"
+	assert.NotNil(t, client.ValidateParseFile(), "Expected ValidateParseFile() to return error for output mode out of range '17777'")
+
+	// Unknown output owner
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
+	client.OutOwner = "no-such-user-for-vault-helper"
+	assert.NotNil(t, client.ValidateParseFile(), "Expected ValidateParseFile() to return error for unknown output owner")
+
+	// Valid output file, mode and owner
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
+	client.Out = "example.groovy.out"
+	client.OutMode = "0640"
+	client.OutOwner = "0:0"
+	assert.Nil(t, client.ValidateParseFile(), "Expected ValidateParseFile() to return nil for valid output file, mode and owner: %v", client.ValidateParseFile())
+}
+
+func TestClient_ValidateFetchEnvironment(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Missing paths
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "", "", "")
+	assert.NotNil(t, client.ValidateFetchEnvironment(nil), "Expected ValidateFetchEnvironment() to return error for missing paths")
+
+	// Empty path
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "", "", "")
+	assert.NotNil(t, client.ValidateFetchEnvironment([]string{"/foo/bar", ""}), "Expected ValidateFetchEnvironment() to return error for empty path")
+
+	// Invalid prefix
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "", "", "")
+	client.EnvPrefix = "APP-"
+	assert.NotNil(t, client.ValidateFetchEnvironment([]string{"/foo/bar"}), "Expected ValidateFetchEnvironment() to return error for invalid prefix 'APP-'")
+
+	// Missing secret id
+	client = Setup("https://google.com", "dead-beef", "", "", "", "", "")
+	assert.NotNil(t, client.ValidateFetchEnvironment([]string{"/foo/bar"}), "Expected ValidateFetchEnvironment() to return error for empty secret id")
+
+	// Valid role id, secret id, paths and prefix
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "", "", "")
+	client.EnvPrefix = "APP_"
+	assert.Nil(t, client.ValidateFetchEnvironment([]string{"/foo/bar", "/foo/baz"}), "Expected ValidateFetchEnvironment() to return nil for valid paths and prefix: %v", client.ValidateFetchEnvironment([]string{"/foo/bar", "/foo/baz"}))
+}
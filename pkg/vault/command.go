@@ -0,0 +1,42 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+// Returned when the command run after the output file changed could not be started, or exited non-zero
+type CommandError struct {
+	Command string
+	Err     error
+}
+
+func (i *CommandError) Error() string {
+	return fmt.Sprintf("Command '%v' failed: %v", i.Command, i.Err)
+}
+
+func (i *CommandError) Unwrap() error {
+	return i.Err
+}
+
+// Runs v.ExecOnChange through the shell, like 'systemctl reload nginx', with its output passed through to ours
+func (v *Client) execOnChange() error {
+	if v.ExecOnChange == "" {
+		return nil
+	}
+
+	logger.Infof("File %v changed, running '%v'", v.outputFile(), v.ExecOnChange)
+
+	command := shellCommand(v.ctx, v.ExecOnChange)
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	err := command.Run()
+	if err != nil {
+		return &CommandError{Command: v.ExecOnChange, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package vault
+
+import (
+	"context"
+	"os/exec"
+)
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
@@ -0,0 +1,122 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+var (
+	// Characters that are not allowed in environment variable names are replaced with an underscore
+	envInvalidCharacters = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// Logs in, fetches the secrets at every path, and returns their keys as environment variables like 'PREFIX_KEY=value',
+// using v.EnvPrefix and upper-casing keys when v.EnvUpperCase is set. Keys at later paths win over earlier ones. The
+// token is revoked before returning, the secrets only live on in the returned environment.
+func (v *Client) FetchEnvironment(roleId, secretId string, paths []string) ([]string, error) {
+	v.RoleId = roleId
+	v.SecretId = secretId
+
+	err := v.ValidateFetchEnvironment(paths)
+	if err != nil {
+		return nil, &ValidationError{Err: err}
+	}
+
+	// Create the token
+	err = v.login()
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make(map[string]string)
+	for _, secretPath := range paths {
+		secret, err := new(Secret).GetPath(v, secretPath, 0)
+		if err != nil {
+			v.revokeToken()
+			return nil, err
+		}
+
+		for key, value := range secret.Data {
+			name := v.envName(key)
+			if _, ok := variables[name]; ok {
+				logger.Warnf("Environment variable %v from %v overrides a previous path", name, secretPath)
+			}
+
+			variables[name], err = envValue(value)
+			if err != nil {
+				v.revokeToken()
+				return nil, fmt.Errorf("Could not convert key '%v' from %v to an environment variable: %w", key, secretPath, err)
+			}
+		}
+	}
+
+	// Revoke the token, unless that would revoke the dynamic secrets we are handing to the command
+	_, err = v.revokeTokenUnlessLeased()
+	if err != nil {
+		return nil, err
+	}
+
+	environment := make([]string, 0, len(variables))
+	for name, value := range variables {
+		environment = append(environment, name+"="+value)
+	}
+	sort.Strings(environment)
+
+	return environment, nil
+}
+
+func (v *Client) ValidateFetchEnvironment(paths []string) error {
+	// Make sure we have what we need to log in
+	err := v.ValidateLogin()
+	if err != nil {
+		return err
+	}
+
+	// Make sure there is at least one path, and none are empty
+	if len(paths) == 0 {
+		return errors.New("Path cannot be empty")
+	}
+
+	for _, secretPath := range paths {
+		if secretPath == "" {
+			return errors.New("Path cannot be empty")
+		}
+	}
+
+	// Make sure the prefix only holds characters valid in an environment variable name
+	if envInvalidCharacters.MatchString(v.EnvPrefix) {
+		return fmt.Errorf("Environment variable prefix '%v' may only contain letters, digits and underscores", v.EnvPrefix)
+	}
+
+	return nil
+}
+
+// The environment variable name for a secret key, like 'PREFIX_USER_NAME' for 'user-name'
+func (v *Client) envName(key string) string {
+	name := v.EnvPrefix + envInvalidCharacters.ReplaceAllString(key, "_")
+	if v.EnvUpperCase {
+		return strings.ToUpper(name)
+	}
+
+	return name
+}
+
+// Strings are used as-is, anything else (numbers, booleans, nested maps or lists) as its JSON encoding
+func envValue(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
@@ -0,0 +1,36 @@
+package vault_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_FetchEnvironment(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"secret/jenkins/admin": {"user-name": "kevin", "port": 8080},
+		"kv/db":                {"password": "bacon", "port": 5432},
+	})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// Keys are prefixed, upper-cased and made valid variable names, with later paths winning
+	client.EnvPrefix = "app_"
+	client.EnvUpperCase = true
+	environment, err := client.FetchEnvironment("dead-beef", "ea7-beef", []string{"secret/jenkins/admin", "kv/db"})
+	assert.Nil(t, err, "Expected FetchEnvironment() to return nil: %v", err)
+	assert.Equal(t, []string{"APP_PASSWORD=bacon", "APP_PORT=5432", "APP_USER_NAME=kevin"}, environment)
+
+	// The token does not outlive the call
+	assert.Equal(t, 1, server.count("POST /v1/auth/token/revoke-self"), "Expected the token to be revoked")
+
+	// Keys are left alone without a prefix or upper-casing
+	client.EnvPrefix = ""
+	client.EnvUpperCase = false
+	environment, err = client.FetchEnvironment("dead-beef", "ea7-beef", []string{"kv/db"})
+	assert.Nil(t, err, "Expected FetchEnvironment() to return nil: %v", err)
+	assert.Equal(t, []string{"password=bacon", "port=5432"}, environment)
+}
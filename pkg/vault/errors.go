@@ -0,0 +1,85 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// When vault emits errors, we marshal them to this struct so it's easier to print out
+type VaultClientErrors struct {
+	Errors []string `json:"errors"`
+}
+
+func (i *VaultClientErrors) Error() string {
+	return strings.Join(i.Errors, ", ")
+}
+
+// Returned when the request never produced a usable response from vault, like a timeout, eof, or connection refused.
+// Method, Path and Duration describe the request, when it got as far as being sent.
+type HTTPError struct {
+	Method   string
+	Path     string
+	Duration time.Duration
+	Err      error
+}
+
+func (i *HTTPError) Error() string {
+	return fmt.Sprintf("Got low-level HTTP error: %v", i.Err)
+}
+
+func (i *HTTPError) Unwrap() error {
+	return i.Err
+}
+
+// Returned when vault responds with a status code we did not expect. Errors holds whatever vault put in the "errors"
+// field of the response body, and may be empty. RequestId is the vault request id of the response, when it had one,
+// so the request can be found again in the audit log.
+type ResponseError struct {
+	Method           string
+	Path             string
+	RequestId        string
+	Duration         time.Duration
+	StatusCode       int
+	ValidStatusCodes []int
+	Errors           *VaultClientErrors
+}
+
+func (i *ResponseError) Error() string {
+	return fmt.Sprintf("Response %v was not one of %v: %v", i.StatusCode, i.ValidStatusCodes, i.Errors)
+}
+
+func (i *ResponseError) Unwrap() error {
+	if i.Errors == nil {
+		return nil
+	}
+
+	return i.Errors
+}
+
+// Returned when the inputs to a Client method are missing or invalid, before any request is made to vault.
+type ValidationError struct {
+	Err error
+}
+
+func (i *ValidationError) Error() string {
+	return i.Err.Error()
+}
+
+func (i *ValidationError) Unwrap() error {
+	return i.Err
+}
+
+// Returned when a template (either a selector or a file) could not be parsed or rendered with the secret data.
+type TemplateError struct {
+	Template string
+	Err      error
+}
+
+func (i *TemplateError) Error() string {
+	return fmt.Sprintf("Could not render template '%v': %v", i.Template, i.Err)
+}
+
+func (i *TemplateError) Unwrap() error {
+	return i.Err
+}
@@ -0,0 +1,131 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	path "path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+// Writes content to file by writing it to a temporary file in the same directory, syncing it to disk, and renaming it
+// over file, so readers only ever see the previous or the new content. A uid or gid of -1 is left as the current
+// user's. Failing to change the owner is only an error when it was asked for explicitly, not kept from the old file.
+func writeFileAtomic(file string, content []byte, mode os.FileMode, uid, gid int, ownerRequired bool) error {
+	tmp, err := writeTempFile(file, content, mode, uid, gid, ownerRequired)
+	if err != nil {
+		return err
+	}
+
+	return renameTempFile(tmp, file)
+}
+
+// Same as writeFileAtomic for callers outside of the Client, with file owned by the current user
+func WriteFileAtomic(file string, content []byte, mode os.FileMode) error {
+	return writeFileAtomic(file, content, mode, -1, -1, false)
+}
+
+// The first half of writeFileAtomic, returning the temporary file with the content, mode and owner of file, which is
+// removed again when anything fails
+func writeTempFile(file string, content []byte, mode os.FileMode, uid, gid int, ownerRequired bool) (name string, err error) {
+	tmp, err := os.CreateTemp(path.Dir(file), "."+path.Base(file)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("Could not create temporary file for '%v': %w", file, err)
+	}
+
+	// Clean up the temporary file if we do not make it to the end
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(content); err != nil {
+		return "", fmt.Errorf("Could not write temporary file for '%v': %w", file, err)
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return "", fmt.Errorf("Could not sync temporary file for '%v': %w", file, err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return "", fmt.Errorf("Could not close temporary file for '%v': %w", file, err)
+	}
+
+	if err = os.Chmod(tmp.Name(), mode); err != nil {
+		return "", fmt.Errorf("Could not change mode of file '%v': %w", file, err)
+	}
+
+	if uid != -1 || gid != -1 {
+		if chownErr := os.Chown(tmp.Name(), uid, gid); chownErr != nil {
+			if ownerRequired {
+				err = fmt.Errorf("Could not change owner of file '%v': %w", file, chownErr)
+				return "", err
+			}
+
+			logger.Warnf("Could not keep the owner of file '%v': %v", file, chownErr)
+		}
+	}
+
+	return tmp.Name(), nil
+}
+
+// The second half of writeFileAtomic, renaming the temporary file over file
+func renameTempFile(tmp, file string) error {
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("Could not rename temporary file to '%v': %w", file, err)
+	}
+
+	syncDir(path.Dir(file))
+	return nil
+}
+
+// Parses an octal file mode, like '0640' or '600'
+func parseFileMode(mode string) (os.FileMode, error) {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || parsed > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("Could not parse file mode '%v', expected an octal mode like '0640'", mode)
+	}
+
+	return os.FileMode(parsed), nil
+}
+
+// Looks up the uid and gid for an owner like 'user', 'user:group', '1000' or '1000:1000'. When no group is given, the
+// gid is -1 so chown leaves the group alone.
+func lookupOwner(owner string) (int, int, error) {
+	parts := strings.SplitN(owner, ":", 2)
+	userName := parts[0]
+
+	uid, err := strconv.Atoi(userName)
+	if err != nil {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Could not look up user '%v': %v", userName, err)
+		}
+
+		uid, _ = strconv.Atoi(u.Uid)
+	}
+
+	if len(parts) < 2 || parts[1] == "" {
+		return uid, -1, nil
+	}
+
+	groupName := parts[1]
+
+	gid, err := strconv.Atoi(groupName)
+	if err != nil {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Could not look up group '%v': %v", groupName, err)
+		}
+
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+
+	return uid, gid, nil
+}
@@ -0,0 +1,111 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_ParseFileOut(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"secret/jenkins/admin": {"username": "kevin", "password": "bacon"},
+	})
+
+	dir := t.TempDir()
+	file := path.Join(dir, "init.groovy.tmpl")
+	out := path.Join(dir, "init.groovy")
+	template := `hudsonRealm.createAccount("((.username))", "((.password))")`
+
+	err := os.WriteFile(file, []byte(template), 0644)
+	assert.Nil(t, err)
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// The parsed file is written to --out with the given mode, and the template is left untouched
+	client.Out = out
+	client.OutMode = "0600"
+	err = client.ParseFile("dead-beef", "ea7-beef", "secret/jenkins/admin", file)
+	assert.Nil(t, err, "Expected ParseFile() to return nil: %v", err)
+
+	parsed, _ := os.ReadFile(out)
+	assert.Equal(t, `hudsonRealm.createAccount("kevin", "bacon")`, string(parsed))
+
+	unparsed, _ := os.ReadFile(file)
+	assert.Equal(t, template, string(unparsed))
+
+	info, _ := os.Stat(out)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Nothing is written when the template fails to render
+	err = os.WriteFile(file, []byte(`((secret "secret/jenkins/admin" "email"))`), 0644)
+	assert.Nil(t, err)
+
+	err = client.ParseFile("dead-beef", "ea7-beef", "secret/jenkins/admin", file)
+	assert.NotNil(t, err, "Expected ParseFile() to return error for missing key 'email'")
+
+	parsed, _ = os.ReadFile(out)
+	assert.Equal(t, `hudsonRealm.createAccount("kevin", "bacon")`, string(parsed))
+
+	// The mode of the existing output file is kept when re-parsing without --out-mode
+	err = os.WriteFile(file, []byte(`((.password))`), 0644)
+	assert.Nil(t, err)
+
+	client.OutMode = ""
+	err = client.ParseFile("dead-beef", "ea7-beef", "secret/jenkins/admin", file)
+	assert.Nil(t, err, "Expected ParseFile() to return nil: %v", err)
+
+	parsed, _ = os.ReadFile(out)
+	assert.Equal(t, "bacon", string(parsed))
+
+	info, _ = os.Stat(out)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(dir)
+	assert.Equal(t, 2, len(entries), "Expected only the template and output file in %v: %v", dir, entries)
+}
+
+func TestClient_ParseFileExecOnChange(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"kv/db": {"password": "bacon"},
+	})
+
+	dir := t.TempDir()
+	file := path.Join(dir, "db.conf.tmpl")
+	out := path.Join(dir, "db.conf")
+	reloads := path.Join(dir, "reloads")
+
+	err := os.WriteFile(file, []byte(`password=((.password))`), 0644)
+	assert.Nil(t, err)
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	client.Out = out
+	client.ExecOnChange = "echo reload >> " + reloads
+
+	// The command runs when the output file is first written, and again only once the secret changes
+	for _, password := range []string{"bacon", "bacon", "eggs"} {
+		server.set("kv/db", map[string]interface{}{"password": password})
+
+		err = client.ParseFile("dead-beef", "ea7-beef", "kv/db", file)
+		assert.Nil(t, err, "Expected ParseFile() to return nil: %v", err)
+	}
+
+	ran, _ := os.ReadFile(reloads)
+	assert.Equal(t, "reload\nreload\n", string(ran))
+
+	// A failing command is reported as a CommandError
+	var commandError *vault.CommandError
+
+	server.set("kv/db", map[string]interface{}{"password": "spam"})
+	client.ExecOnChange = "exit 3"
+	err = client.ParseFile("dead-beef", "ea7-beef", "kv/db", file)
+	assert.True(t, errors.As(err, &commandError), "Expected ParseFile() to return a CommandError for 'exit 3': %v", err)
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+// +build !windows
+
+package vault
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Returns the uid and gid owning the file
+func fileOwner(info os.FileInfo) (int, int) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ! ok {
+		return -1, -1
+	}
+
+	return int(stat.Uid), int(stat.Gid)
+}
+
+// Syncs the directory so a rename in to it survives a crash. This is best effort, the rename itself already happened.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	_ = d.Sync()
+}
+
+// Makes sure the directory is not writable by other users, unless it has the sticky bit set like /tmp, and that it
+// belongs to us or root
+func checkPrivateDir(dir string, info os.FileInfo) error {
+	if info.Mode().Perm()&0022 != 0 && info.Mode()&os.ModeSticky == 0 {
+		return fmt.Errorf("The directory %v is writable by other users (mode %04o)", dir, info.Mode().Perm())
+	}
+
+	if uid, _ := fileOwner(info); uid != 0 && uid != os.Getuid() {
+		return fmt.Errorf("The directory %v belongs to another user (uid %v)", dir, uid)
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package vault
+
+import (
+	"os"
+)
+
+// Windows has no uid and gid, the owner is left alone
+func fileOwner(info os.FileInfo) (int, int) {
+	return -1, -1
+}
+
+// Directories cannot be synced on windows
+func syncDir(dir string) {}
+
+// Access to directories is controlled by ACLs on windows, which the mode does not reflect
+func checkPrivateDir(dir string, info os.FileInfo) error {
+	return nil
+}
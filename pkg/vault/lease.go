@@ -0,0 +1,119 @@
+package vault
+
+import (
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+// Remembers the lease of a dynamic secret fetched with the current token, so it can be revoked when we are done
+func (v *Client) trackLease(secret *Secret) {
+	if secret.LeaseId == "" {
+		return
+	}
+
+	for _, leaseId := range v.leaseIds {
+		if leaseId == secret.LeaseId {
+			return
+		}
+	}
+
+	v.leaseIds = append(v.leaseIds, secret.LeaseId)
+}
+
+// Renews the lease of a dynamic secret for another lease duration, updating secret with the renewed lease duration.
+// Returns false when the secret has no renewable lease, or when the lease cannot be renewed for as long as before
+// because it is close to its max TTL, in which case the secret should be fetched again to get fresh credentials.
+func (v *Client) renewLease(secret *Secret) bool {
+	if secret.LeaseId == "" || ! secret.Renewable {
+		return false
+	}
+
+	renewed, err := new(Lease).Renew(v, secret.LeaseId, secret.LeaseDuration)
+	if err != nil {
+		logger.Warnf("Could not renew lease %v, fetching the secret again: %v", secret.LeaseId, err)
+		return false
+	}
+
+	if renewed.LeaseDuration < secret.LeaseDuration {
+		logger.Infof("Lease %v is close to its max TTL, fetching the secret again", secret.LeaseId)
+		return false
+	}
+
+	logger.Debugf("Renewed lease %v for another %vs", secret.LeaseId, renewed.LeaseDuration)
+	secret.LeaseDuration = renewed.LeaseDuration
+	return true
+}
+
+// Fetches a secret for the 'secret' template function. Dynamic secrets are kept for the lifetime of the Client and
+// their lease is renewed once it is about to run out, so re-rendering a file with 'parse --watch', or a selector of
+// 'serve', does not create new credentials every time. They are only fetched again once their lease hits its max TTL.
+func (v *Client) getSecretCached(secretPath string) (*Secret, error) {
+	if cached, ok := v.leasedSecrets[secretPath]; ok {
+		if time.Now().Before(cached.expires) {
+			return cached.secret, nil
+		}
+
+		if v.renewLease(cached.secret) {
+			cached.expires = time.Now().Add(leaseRenewDelay(cached.secret.LeaseDuration))
+			return cached.secret, nil
+		}
+	}
+
+	secret, err := new(Secret).GetPath(v, secretPath, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.LeaseId == "" {
+		delete(v.leasedSecrets, secretPath)
+		return secret, nil
+	}
+
+	if v.leasedSecrets == nil {
+		v.leasedSecrets = make(map[string]*cachedSecret)
+	}
+	v.leasedSecrets[secretPath] = &cachedSecret{secret: secret, expires: time.Now().Add(leaseRenewDelay(secret.LeaseDuration))}
+
+	return secret, nil
+}
+
+// Revokes the leases of every dynamic secret fetched with the current token on the way out, which is best effort since
+// we are stopping either way. Leases that already expired are revoked without an error.
+func (v *Client) revokeLeases() {
+	for _, leaseId := range v.leaseIds {
+		err := new(Lease).Revoke(v, leaseId)
+		if err != nil {
+			logger.Warnf("Could not revoke lease %v: %v", leaseId, err)
+		}
+	}
+
+	v.leaseIds = nil
+	v.leasedSecrets = nil
+}
+
+// Revokes the token once we are done with it. That revokes the leases of the dynamic secrets fetched with it as well,
+// which would leave whatever they were handed to with dead credentials, so the token is left to expire instead unless
+// v.RevokeLeasedToken is set. Its accessor is logged so it can still be revoked by hand. Returns whether the token was
+// revoked.
+func (v *Client) revokeTokenUnlessLeased() (bool, error) {
+	leased := len(v.leaseIds)
+	v.leaseIds = nil
+	v.leasedSecrets = nil
+
+	if leased > 0 && ! v.RevokeLeasedToken {
+		accessor, ttl := "", 0
+		if v.tokenAuth != nil {
+			accessor, ttl = v.tokenAuth.Accessor, v.tokenAuth.LeaseDuration
+		}
+
+		logger.Warnf("Not revoking token with accessor %v, which would revoke the leases of %v dynamic secret(s) with it, it expires in %vs", accessor, leased, ttl)
+		return false, nil
+	}
+
+	if leased > 0 {
+		logger.Warnf("Revoking token as asked, which revokes the leases of %v dynamic secret(s) with it", leased)
+	}
+
+	return true, v.Auth.Token.RevokeSelf(v)
+}
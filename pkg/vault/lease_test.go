@@ -0,0 +1,234 @@
+package vault_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_ParseFileDynamicSecret(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+	server.leaseDuration = 3600
+
+	dir := t.TempDir()
+	file := path.Join(dir, "db.conf.tmpl")
+	out := path.Join(dir, "db.conf")
+	assert.Nil(t, os.WriteFile(file, []byte(`user=((.username))`), 0644))
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+
+	// The token is left to expire, since revoking it would revoke the credentials we just rendered
+	err = client.ParseFile("dead-beef", "ea7-beef", "database/creds/app", file)
+	assert.Nil(t, err, "Expected ParseFile() to return nil for dynamic secret: %v", err)
+
+	parsed, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, "user=v-app-1", string(parsed))
+
+	assert.Equal(t, 0, server.count("POST /v1/auth/token/revoke-self"), "Expected the token to be left to expire")
+	assert.Equal(t, 0, server.count("PUT /v1/sys/leases/revoke"), "Expected the lease to be left to expire")
+
+	// Unless asked to revoke it anyway, taking the credentials with it
+	client.RevokeLeasedToken = true
+
+	err = client.ParseFile("dead-beef", "ea7-beef", "database/creds/app", file)
+	assert.Nil(t, err, "Expected ParseFile() to return nil for dynamic secret: %v", err)
+	assert.Equal(t, 1, server.count("POST /v1/auth/token/revoke-self"), "Expected the token to be revoked")
+
+	parsed, err = os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, "user=v-app-2", string(parsed))
+}
+
+func TestClient_WatchFileDynamicSecret(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+	server.leaseDuration = 1
+	server.maxRenewals = 2
+
+	// Renew every 20ms rather than every 2/3 of a second
+	defer func(fraction float64) { vault.LeaseRenewFraction = fraction }(vault.LeaseRenewFraction)
+	vault.LeaseRenewFraction = 0.02
+
+	dir := t.TempDir()
+	file := path.Join(dir, "db.conf.tmpl")
+	out := path.Join(dir, "db.conf")
+	assert.Nil(t, os.WriteFile(file, []byte(`user=((.username))`), 0644))
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.WatchFile(ctx, "dead-beef", "ea7-beef", "database/creds/app", file, time.Hour)
+	}()
+
+	assert.Eventually(t, func() bool {
+		parsed, _ := os.ReadFile(out)
+		return string(parsed) == "user=v-app-1"
+	}, time.Second, 5*time.Millisecond)
+
+	// The lease is renewed, and fresh credentials are only fetched once it hits its max TTL
+	assert.Eventually(t, func() bool {
+		parsed, _ := os.ReadFile(out)
+		return string(parsed) != "user=v-app-1"
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done, "Expected WatchFile() to return nil once stopped")
+
+	assert.GreaterOrEqual(t, server.count("PUT /v1/sys/leases/renew"), 3, "Expected the lease to be renewed until its max TTL")
+	assert.GreaterOrEqual(t, server.count("GET /v1/database/creds/app"), 2, "Expected fresh credentials past the max TTL")
+
+	// Stopping revokes the leases and the token
+	assert.GreaterOrEqual(t, server.count("PUT /v1/sys/leases/revoke"), 2, "Expected the leases to be revoked once stopped")
+	assert.Equal(t, 1, server.count("POST /v1/auth/token/revoke-self"), "Expected the token to be revoked once stopped")
+}
+
+func TestClient_WatchFileLoginAgain(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+	server.leaseDuration = 3600
+	server.tokenDuration = 1
+
+	// The fake vault cannot renew tokens, so the watch logs in again every 20ms rather than every 2/3 of a second
+	defer func(fraction float64) { vault.LeaseRenewFraction = fraction }(vault.LeaseRenewFraction)
+	vault.LeaseRenewFraction = 0.02
+
+	dir := t.TempDir()
+	file := path.Join(dir, "db.conf.tmpl")
+	out := path.Join(dir, "db.conf")
+	assert.Nil(t, os.WriteFile(file, []byte(`user=((.username))`), 0644))
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.WatchFile(ctx, "dead-beef", "ea7-beef", "database/creds/app", file, time.Hour)
+	}()
+
+	// The previous token is revoked once the credentials were fetched again with the new one
+	assert.Eventually(t, func() bool {
+		revoked := server.revokedTokens()
+		return len(revoked) > 0 && revoked[0] == "dead-c0de"
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done, "Expected WatchFile() to return nil once stopped")
+
+	parsed, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "user=v-app-1", string(parsed), "Expected fresh credentials from the new token")
+	assert.GreaterOrEqual(t, server.count("GET /v1/database/creds/app"), 2)
+}
+
+func TestClient_WatchFileRenewedLeaseRenders(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"kv/app": {"port": "8080"},
+	})
+	server.leaseDuration = 1
+	server.maxRenewals = 1000
+
+	// Renew every 20ms rather than every 2/3 of a second
+	defer func(fraction float64) { vault.LeaseRenewFraction = fraction }(vault.LeaseRenewFraction)
+	vault.LeaseRenewFraction = 0.02
+
+	dir := t.TempDir()
+	file := path.Join(dir, "db.conf.tmpl")
+	out := path.Join(dir, "db.conf")
+	assert.Nil(t, os.WriteFile(file, []byte(`user=((.username)) port=((secret "kv/app" "port"))`), 0644))
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.WatchFile(ctx, "dead-beef", "ea7-beef", "database/creds/app", file, time.Hour)
+	}()
+
+	assert.Eventually(t, func() bool {
+		parsed, _ := os.ReadFile(out)
+		return string(parsed) == "user=v-app-1 port=8080"
+	}, time.Second, 5*time.Millisecond)
+
+	// Secrets the template fetches itself are picked up while the lease of the credentials is renewed
+	server.set("kv/app", map[string]interface{}{"port": "8443"})
+	assert.Eventually(t, func() bool {
+		parsed, _ := os.ReadFile(out)
+		return string(parsed) == "user=v-app-1 port=8443"
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done, "Expected WatchFile() to return nil once stopped")
+
+	assert.Equal(t, 1, server.count("GET /v1/database/creds/app"), "Expected the credentials to be renewed rather than fetched again")
+}
+
+func TestClient_WatchFileDynamicSecretFunction(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"kv/app": {"port": "8080"},
+	})
+	server.leaseDuration = 1
+	server.maxRenewals = 1000
+
+	// Renew every 20ms rather than every 2/3 of a second
+	defer func(fraction float64) { vault.LeaseRenewFraction = fraction }(vault.LeaseRenewFraction)
+	vault.LeaseRenewFraction = 0.02
+
+	dir := t.TempDir()
+	file := path.Join(dir, "db.conf.tmpl")
+	out := path.Join(dir, "db.conf")
+	assert.Nil(t, os.WriteFile(file, []byte(`user=((secret "database/creds/app" "username")) port=((.port))`), 0644))
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.WatchFile(ctx, "dead-beef", "ea7-beef", "kv/app", file, 5*time.Millisecond)
+	}()
+
+	// Credentials the template fetches itself have their lease renewed, rather than being created again every render
+	assert.Eventually(t, func() bool {
+		return server.count("PUT /v1/sys/leases/renew") >= 3 && server.count("GET /v1/kv/app") >= 10
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done, "Expected WatchFile() to return nil once stopped")
+
+	parsed, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, "user=v-app-1 port=8080", string(parsed))
+	assert.Equal(t, 1, server.count("GET /v1/database/creds/app"), "Expected the credentials to be renewed rather than fetched again")
+	assert.Equal(t, 1, server.count("PUT /v1/sys/leases/revoke"), "Expected the lease to be revoked once stopped")
+
+	// Once the lease hits its max TTL, fresh credentials are fetched
+	server.maxRenewals = 0
+	assert.Nil(t, os.Remove(out))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		done <- client.WatchFile(ctx, "dead-beef", "ea7-beef", "kv/app", file, time.Hour)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return server.count("GET /v1/database/creds/app") >= 3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done, "Expected WatchFile() to return nil once stopped")
+}
@@ -2,28 +2,26 @@ package vault
 
 import (
 	"context"
-
-	"github.com/Indellient/vault-helper/pkg/logger"
 )
 
 // Creates, validates, and initializes a new Client with specified params
-func NewVaultClient(ctx context.Context, addr string, insecure bool) *Client {
+func NewVaultClient(ctx context.Context, addr string, tlsConfig TLSConfig) (*Client, error) {
 	vault := new(Client)
 	vault.Address = addr
-	vault.Insecure = insecure
+	vault.TLS = tlsConfig
 	vault.ctx = ctx
 
 	// Basic validation of input
 	err := vault.Validate()
 	if err != nil {
-		logger.Fatalf("%v", err)
+		return nil, &ValidationError{Err: err}
 	}
 
 	// Extended validation of input -- can we actually communicate with vault?
 	err = vault.ExtendedValidate()
 	if err != nil {
-		logger.Fatalf("%v", err)
+		return nil, err
 	}
 
-	return vault
+	return vault, nil
 }
@@ -0,0 +1,45 @@
+package vault_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_Namespace(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"secret/jenkins/admin": {"username": "kevin"},
+	})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// Without a namespace, every request goes to the root namespace
+	_, err = client.FetchSecret("dead-c0de", "secret/jenkins/admin", "((.username))")
+	assert.Nil(t, err, "Expected FetchSecret() to return nil: %v", err)
+	assert.Equal(t, "", server.namespace("GET /v1/secret/data/jenkins/admin"))
+
+	// Logging in and the token itself default to the namespace of the secrets
+	client.Namespace = "team-a/app"
+	_, err = client.FetchEnvironment("dead-beef", "ea7-beef", []string{"secret/jenkins/admin"})
+	assert.Nil(t, err, "Expected FetchEnvironment() to return nil: %v", err)
+	assert.Equal(t, "team-a/app", server.namespace("POST /v1/auth/approle/login"))
+	assert.Equal(t, "team-a/app", server.namespace("GET /v1/sys/internal/ui/mounts/secret/jenkins/admin"))
+	assert.Equal(t, "team-a/app", server.namespace("GET /v1/secret/data/jenkins/admin"))
+	assert.Equal(t, "team-a/app", server.namespace("POST /v1/auth/token/revoke-self"))
+
+	// An auth namespace logs in with an auth method mounted in a parent namespace, the secrets stay where they are
+	client.AuthNamespace = "team-a"
+	_, err = client.FetchEnvironment("dead-beef", "ea7-beef", []string{"secret/jenkins/admin"})
+	assert.Nil(t, err, "Expected FetchEnvironment() to return nil: %v", err)
+	assert.Equal(t, "team-a", server.namespace("POST /v1/auth/approle/login"))
+	assert.Equal(t, "team-a/app", server.namespace("GET /v1/secret/data/jenkins/admin"))
+	assert.Equal(t, "team-a", server.namespace("POST /v1/auth/token/revoke-self"))
+
+	// The health check is only served by the root namespace
+	_, err = client.SystemHealth.Reload(client)
+	assert.Nil(t, err, "Expected SystemHealth.Reload() to return nil: %v", err)
+	assert.Equal(t, "", server.namespace("GET /v1/sys/health"))
+}
@@ -0,0 +1,260 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+var (
+	// Short names for the parameters of the PKI issue endpoint, like 'cn=host.example.com' in the pkiCert function
+	PKIIssueParameters = map[string]string{
+		"cn":  "common_name",
+		"alt": "alt_names",
+		"ip":  "ip_sans",
+		"uri": "uri_sans",
+	}
+)
+
+type PKICertificate struct {
+	*Response
+}
+
+// Issues a new certificate and private key from a PKI role, like 'pki/issue/web', with parameters like common_name,
+// alt_names, ip_sans and ttl
+func (i *PKICertificate) Issue(v *Client, issuePath string, parameters map[string]string) (*PKICertificate, error) {
+	response, err := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetBody(parameters).SetResult(i).SetError(VaultClientErrors{}).Post(strings.TrimPrefix(issuePath, "/"))
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the private key is secret, the certificate and its serial number are fine to log
+	privateKey, _ := i.Data["private_key"].(string)
+	redactValues(privateKey)
+
+	return i, nil
+}
+
+// Where IssueCertificate writes the certificate, its private key, and the CA chain. The CA chain is optional.
+type CertificateFiles struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+// Logs in, issues a certificate from the PKI role at issuePath (like 'pki/issue/web'), writes it to files, and revokes
+// the token. The private key is written with mode 0600, the certificate and CA chain with 0644, owned by v.OutOwner
+// when given.
+func (v *Client) IssueCertificate(roleId, secretId, issuePath string, parameters map[string]string, files CertificateFiles) error {
+	v.RoleId = roleId
+	v.SecretId = secretId
+
+	err := v.ValidateIssueCertificate(issuePath, parameters, files)
+	if err != nil {
+		return &ValidationError{Err: err}
+	}
+
+	// Create the token
+	err = v.login()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := new(PKICertificate).Issue(v, issuePath, parameters)
+	if err != nil {
+		v.revokeToken()
+		return err
+	}
+
+	// Certificates issued with a lease are revoked along with the token
+	v.trackLease(&Secret{LeaseId: certificate.LeaseID})
+
+	err = v.writeCertificate(certificate.Data, files)
+	if err != nil {
+		v.revokeToken()
+		return err
+	}
+
+	_, err = v.revokeTokenUnlessLeased()
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Successfully issued certificate %v from %v to %v", certificate.Data["serial_number"], issuePath, files.Cert)
+	return nil
+}
+
+func (v *Client) ValidateIssueCertificate(issuePath string, parameters map[string]string, files CertificateFiles) error {
+	// Make sure we have what we need to log in
+	err := v.ValidateLogin()
+	if err != nil {
+		return err
+	}
+
+	// Make sure issue path is non-empty
+	if issuePath == "" {
+		return errors.New("Issue path cannot be empty")
+	}
+
+	// Make sure common name is non-empty
+	if parameters["common_name"] == "" {
+		return errors.New("Common name cannot be empty")
+	}
+
+	// Make sure we know where to write the certificate and private key
+	if files.Cert == "" || files.Key == "" {
+		return errors.New("Certificate and private key output files cannot be empty")
+	}
+
+	// Make sure output owner is a known user (and group)
+	if v.OutOwner != "" {
+		if _, _, err := lookupOwner(v.OutOwner); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes the certificate, private key and CA chain issued by vault to their files atomically
+func (v *Client) writeCertificate(data map[string]interface{}, files CertificateFiles) error {
+	uid, gid := -1, -1
+	if v.OutOwner != "" {
+		var err error
+
+		uid, gid, err = lookupOwner(v.OutOwner)
+		if err != nil {
+			return err
+		}
+	}
+
+	certificate, _ := data["certificate"].(string)
+	privateKey, _ := data["private_key"].(string)
+	if certificate == "" || privateKey == "" {
+		return errors.New("Vault did not return a certificate and private key")
+	}
+
+	// Write every file before replacing any, so a failed write leaves the previous certificate and key in place
+	type tempFile struct{ tmp, file string }
+
+	var written []tempFile
+	write := func(file, content string, mode os.FileMode) error {
+		tmp, err := writeTempFile(file, []byte(content+"\n"), mode, uid, gid, v.OutOwner != "")
+		if err == nil {
+			written = append(written, tempFile{tmp: tmp, file: file})
+		}
+		return err
+	}
+
+	err := write(files.Cert, certificate, 0644)
+	if err == nil && files.CA != "" {
+		err = write(files.CA, caChain(data), 0644)
+	}
+	if err == nil {
+		err = write(files.Key, privateKey, 0600)
+	}
+
+	// The private key is renamed last, once the certificate it belongs to is in place
+	for _, file := range written {
+		if err != nil {
+			_ = os.Remove(file.tmp)
+			continue
+		}
+
+		err = renameTempFile(file.tmp, file.file)
+	}
+
+	return err
+}
+
+// The CA chain of an issued certificate, which is just the issuing CA for older vault versions
+func caChain(data map[string]interface{}) string {
+	chain, _ := data["ca_chain"].([]interface{})
+
+	var certificates []string
+	for _, certificate := range chain {
+		if certificate, ok := certificate.(string); ok {
+			certificates = append(certificates, certificate)
+		}
+	}
+
+	if len(certificates) == 0 {
+		issuingCA, _ := data["issuing_ca"].(string)
+		return issuingCA
+	}
+
+	return strings.Join(certificates, "\n")
+}
+
+// Parses parameters of the PKI issue endpoint like 'cn=host.example.com' or 'ttl=72h'
+func parsePKIParameters(arguments []string) (map[string]string, error) {
+	parameters := make(map[string]string)
+
+	for _, argument := range arguments {
+		parts := strings.SplitN(argument, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Could not parse certificate parameter '%v', expected one like 'cn=host.example.com'", argument)
+		}
+
+		name := parts[0]
+		if long, ok := PKIIssueParameters[name]; ok {
+			name = long
+		}
+
+		parameters[name] = parts[1]
+	}
+
+	return parameters, nil
+}
+
+// Issues a certificate for the pkiCert template function. Certificates are kept for the lifetime of the Client until
+// most of their validity has passed, so re-rendering a file with 'parse --watch' does not issue a new certificate (and
+// run --exec-on-change) every interval.
+func (v *Client) issueCachedCertificate(issuePath string, parameters map[string]string) (map[string]interface{}, error) {
+	names := make([]string, 0, len(parameters))
+	for name, value := range parameters {
+		names = append(names, name+"="+value)
+	}
+	sort.Strings(names)
+	key := issuePath + "?" + strings.Join(names, "&")
+
+	if cached, ok := v.certificates[key]; ok && time.Now().Before(cached.expires) {
+		return cached.secret.Data, nil
+	}
+
+	certificate, err := new(PKICertificate).Issue(v, issuePath, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &Secret{Data: certificate.Data, LeaseId: certificate.LeaseID, LeaseDuration: certificate.LeaseDuration}
+	v.trackLease(secret)
+
+	if v.certificates == nil {
+		v.certificates = make(map[string]*cachedSecret)
+	}
+	v.certificates[key] = &cachedSecret{secret: secret, expires: certificateRenewTime(certificate.Data)}
+
+	return secret.Data, nil
+}
+
+// The time after which a certificate should be issued again, once most of its validity has passed
+func certificateRenewTime(data map[string]interface{}) time.Time {
+	now := time.Now()
+
+	expiration, _ := data["expiration"].(float64)
+	if int64(expiration) <= now.Unix() {
+		return now
+	}
+
+	validity := time.Unix(int64(expiration), 0).Sub(now)
+	return now.Add(time.Duration(float64(validity) * LeaseRenewFraction))
+}
@@ -1,20 +1,188 @@
 package vault
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
 )
 
 type Secret struct {
 	Data          map[string]interface{} `json:"data"`
+	Metadata      map[string]interface{} `json:"-"`
 	LeaseDuration int                    `json:"lease_duration"`
 	LeaseId       string                 `json:"lease_id"`
 	Renewable     bool                   `json:"renewable"`
 }
 
-func (i *Secret) Get(v *Client) *Secret {
-	response, err := v.client.NewRequest().SetContext(v.ctx).SetHeader("X-Vault-Token", v.Token).SetResult(i).SetError(VaultClientErrors{}).Get(v.Path)
+type SecretList struct {
+	Data SecretListData `json:"data"`
+}
+
+type SecretListData struct {
+	Keys []string `json:"keys"`
+}
+
+type SecretPutInput struct {
+	Data    map[string]interface{} `json:"data"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// Fetches the secret at v.Path. For KV v2 mounts the path is rewritten to the mount's 'data/' path, and the secret's
+// data and metadata are unwrapped from the response, so callers see the same shape of Data for both KV versions. When
+// v.Version is set, that version of the KV v2 secret is fetched instead of the latest.
+func (i *Secret) Get(v *Client) (*Secret, error) {
+	return i.GetPath(v, v.Path, v.Version)
+}
+
+// Same as Get, for a secret at any path rather than v.Path
+func (i *Secret) GetPath(v *Client, logicalPath string, version int) (*Secret, error) {
+	// Start from scratch, json would otherwise merge the response in to the data we fetched last time
+	*i = Secret{}
+
+	mount, err := v.secretMount(logicalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	secretPath := logicalPath
+	if mount.KVVersion() == 2 {
+		secretPath = mount.APIPath(logicalPath, "data")
+	}
+
+	request := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetResult(i).SetError(VaultClientErrors{})
+	if version > 0 {
+		if mount.KVVersion() != 2 {
+			return nil, &ValidationError{Err: fmt.Errorf("Version %v was requested, but %v is not on a KV v2 mount", version, logicalPath)}
+		}
+
+		request.SetQueryParam("version", strconv.Itoa(version))
+	}
+
+	response, err := request.Get(secretPath)
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	if mount.KVVersion() == 2 {
+		i.Metadata, _ = i.Data["metadata"].(map[string]interface{})
+		i.Data, _ = i.Data["data"].(map[string]interface{})
+	}
+
+	redactValues(i.Data)
+
+	// Dynamic secrets, like database credentials, are only valid for as long as their lease
+	v.trackLease(i)
+
+	return i, nil
+}
+
+// Writes data to the secret at logicalPath, replacing whatever was there. For KV v2 mounts the data is written to the
+// mount's 'data/' path, checked against cas when it is not negative, and the metadata of the new version ends up in
+// Metadata. Check-and-set is not supported by KV v1.
+func (i *Secret) Put(v *Client, logicalPath string, data map[string]interface{}, cas int) (*Secret, error) {
+	*i = Secret{}
+
+	redactValues(data)
+
+	mount, err := v.secretMount(logicalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var body interface{} = data
+	secretPath := logicalPath
+	if mount.KVVersion() == 2 {
+		input := &SecretPutInput{Data: data}
+		if cas >= 0 {
+			input.Options = map[string]interface{}{"cas": cas}
+		}
+
+		body = input
+		secretPath = mount.APIPath(logicalPath, "data")
+	} else if cas >= 0 {
+		return nil, &ValidationError{Err: fmt.Errorf("Check-and-set was requested, but %v is not on a KV v2 mount", logicalPath)}
+	}
+
+	response, err := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetBody(body).SetResult(i).SetError(VaultClientErrors{}).Post(secretPath)
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		return nil, err
+	}
+
+	if mount.KVVersion() == 2 {
+		i.Metadata = i.Data
+	}
+	i.Data = data
+
+	return i, nil
+}
+
+// The KV v2 version in the metadata of a secret, or 0 for KV v1 secrets
+func (i *Secret) CurrentVersion() int {
+	version, _ := i.Metadata["version"].(float64)
+	return int(version)
+}
+
+// Lists the keys under logicalPath with the LIST verb, where keys ending in '/' hold more keys. For KV v2 mounts the
+// path is rewritten to the mount's 'metadata/' path.
+func (i *SecretList) list(v *Client, mount *SystemMount, logicalPath string) ([]string, error) {
+	*i = SecretList{}
+
+	// Keys are listed under 'secret/jenkins/', so the root of a mount, 'secret/', is rewritten like any other path
+	listPath := strings.TrimSuffix(logicalPath, "/") + "/"
+	if mount.KVVersion() == 2 {
+		listPath = mount.APIPath(listPath, "metadata")
+	}
+
+	response, err := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetResult(i).SetError(VaultClientErrors{}).Execute("LIST", listPath)
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.Data.Keys, nil
+}
+
+// Registers every string nested in the value with the logger, so it is masked in any message logged from now on.
+// Every secret value read from or written to vault goes through here, so none of them ever shows up in the logs.
+func redactValues(value interface{}) {
+	switch typed := value.(type) {
+	case string:
+		logger.Redact(typed)
+	case map[string]interface{}:
+		for _, nested := range typed {
+			redactValues(nested)
+		}
+	case []interface{}:
+		for _, nested := range typed {
+			redactValues(nested)
+		}
+	}
+}
+
+// Looks up the mount for the secret path. Tokens that may not look up the mount get an empty one back, which means the
+// path and response are used as-is, the same as a KV v1 mount.
+func (v *Client) secretMount(secretPath string) (*SystemMount, error) {
+	mount := new(SystemMount)
+
+	_, err := mount.Reload(v, secretPath)
+	if err != nil {
+		var responseError *ResponseError
+		if ! errors.As(err, &responseError) {
+			return nil, err
+		}
 
-	v.checkResponseForErrors(response, err, http.StatusOK)
+		logger.Debugf("Could not look up the mount for %v, treating it as KV v1: %v", secretPath, err)
+		return new(SystemMount), nil
+	}
 
-	return i
+	return mount, nil
 }
@@ -0,0 +1,146 @@
+package vault_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_ValidateWriteSecret(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Missing token
+	client = Setup("https://google.com", "", "", "", "secret/app", "", "")
+	assert.NotNil(t, client.ValidateWriteSecret(map[string]interface{}{"user": "kevin"}), "Expected ValidateWriteSecret() to return error for empty token")
+
+	// Missing path
+	client = Setup("https://google.com", "", "", "dead-c0de", "", "", "")
+	assert.NotNil(t, client.ValidateWriteSecret(map[string]interface{}{"user": "kevin"}), "Expected ValidateWriteSecret() to return error for empty path")
+
+	// Missing data
+	client = Setup("https://google.com", "", "", "dead-c0de", "secret/app", "", "")
+	assert.NotNil(t, client.ValidateWriteSecret(map[string]interface{}{}), "Expected ValidateWriteSecret() to return error for empty data")
+
+	// Empty key
+	assert.NotNil(t, client.ValidateWriteSecret(map[string]interface{}{"": "kevin"}), "Expected ValidateWriteSecret() to return error for empty key")
+
+	// Valid data
+	assert.Nil(t, client.ValidateWriteSecret(map[string]interface{}{"user": "kevin"}), "Expected ValidateWriteSecret() to return nil for valid data")
+}
+
+func TestClient_PutSecret(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// KV v2, where cas 0 means the secret must not exist yet
+	version, err := client.PutSecret("dead-c0de", "secret/app", map[string]interface{}{"user": "kevin", "password": "bacon"}, 0)
+	assert.Nil(t, err, "Expected PutSecret() to return nil: %v", err)
+	assert.Equal(t, 1, version)
+	assert.Equal(t, 1, server.count("POST /v1/secret/data/app"))
+
+	_, err = client.PutSecret("dead-c0de", "secret/app", map[string]interface{}{"user": "kevin"}, 0)
+	assert.NotNil(t, err, "Expected PutSecret() to return error for a check-and-set mismatch")
+
+	version, err = client.PutSecret("dead-c0de", "secret/app", map[string]interface{}{"user": "stuart"}, -1)
+	assert.Nil(t, err, "Expected PutSecret() to return nil without check-and-set: %v", err)
+	assert.Equal(t, 2, version)
+
+	secret, err := client.FetchSecret("dead-c0de", "secret/app", "((.user)) ((.password))")
+	assert.Nil(t, err)
+	assert.Equal(t, "stuart <no value>", secret, "Expected PutSecret() to replace the keys of the secret")
+
+	// KV v1 has no versions, nor check-and-set
+	version, err = client.PutSecret("dead-c0de", "kv/app", map[string]interface{}{"user": "kevin"}, -1)
+	assert.Nil(t, err, "Expected PutSecret() to return nil for KV v1: %v", err)
+	assert.Equal(t, 0, version)
+	assert.Equal(t, 1, server.count("POST /v1/kv/app"))
+
+	_, err = client.PutSecret("dead-c0de", "kv/app", map[string]interface{}{"user": "kevin"}, 0)
+	assert.NotNil(t, err, "Expected PutSecret() to return error for check-and-set on KV v1")
+}
+
+func TestClient_PatchSecret(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"secret/app": {"user": "kevin", "password": "bacon"},
+		"kv/app":     {"user": "kevin", "password": "bacon"},
+	})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	for _, secretPath := range []string{"secret/app", "kv/app"} {
+		_, err = client.PatchSecret("dead-c0de", secretPath, map[string]interface{}{"password": "eggs"}, -1)
+		assert.Nil(t, err, "Expected PatchSecret() to return nil for %v: %v", secretPath, err)
+
+		secret, err := client.FetchSecret("dead-c0de", secretPath, "((.user)) ((.password))")
+		assert.Nil(t, err)
+		assert.Equal(t, "kevin eggs", secret, "Expected PatchSecret() to keep the other keys of %v", secretPath)
+	}
+
+	// The version that was read is checked, a stale one given explicitly fails
+	_, err = client.PatchSecret("dead-c0de", "secret/app", map[string]interface{}{"password": "ham"}, 1)
+	assert.NotNil(t, err, "Expected PatchSecret() to return error for a check-and-set mismatch")
+
+	// There is nothing to patch in a missing secret
+	_, err = client.PatchSecret("dead-c0de", "secret/missing", map[string]interface{}{"password": "ham"}, -1)
+	assert.NotNil(t, err, "Expected PatchSecret() to return error for a missing secret")
+}
+
+func TestClient_ValidateListSecrets(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Missing token
+	client = Setup("https://google.com", "", "", "", "secret/jenkins", "", "")
+	assert.NotNil(t, client.ValidateListSecrets(), "Expected ValidateListSecrets() to return error for empty token")
+
+	// Missing path
+	client = Setup("https://google.com", "", "", "dead-c0de", "/", "", "")
+	assert.NotNil(t, client.ValidateListSecrets(), "Expected ValidateListSecrets() to return error for empty path")
+
+	// Valid path
+	client = Setup("https://google.com", "", "", "dead-c0de", "secret/jenkins", "", "")
+	assert.Nil(t, client.ValidateListSecrets(), "Expected ValidateListSecrets() to return nil for valid path")
+}
+
+func TestClient_ListSecrets(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"secret/jenkins/admin":          {"password": "bacon"},
+		"secret/jenkins/dev/user/admin": {"password": "eggs"},
+		"secret/jenkins/dev/user/kevin": {"password": "ham"},
+		"kv/jenkins/admin":              {"password": "bacon"},
+		"kv/jenkins/dev/admin":          {"password": "eggs"},
+	})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// KV v2 lists the metadata path
+	keys, err := client.ListSecrets("dead-c0de", "secret/jenkins", false)
+	assert.Nil(t, err, "Expected ListSecrets() to return nil: %v", err)
+	assert.Equal(t, []string{"admin", "dev/"}, keys)
+	assert.Equal(t, 1, server.count("LIST /v1/secret/metadata/jenkins/"))
+
+	keys, err = client.ListSecrets("dead-c0de", "secret/jenkins/", true)
+	assert.Nil(t, err, "Expected ListSecrets() to return nil when recursive: %v", err)
+	assert.Equal(t, []string{"admin", "dev/user/admin", "dev/user/kevin"}, keys)
+
+	// The root of a mount
+	keys, err = client.ListSecrets("dead-c0de", "secret", false)
+	assert.Nil(t, err, "Expected ListSecrets() to return nil for the root of a mount: %v", err)
+	assert.Equal(t, []string{"jenkins/"}, keys)
+	assert.Equal(t, 1, server.count("LIST /v1/secret/metadata/"))
+
+	// KV v1 lists the path as is
+	keys, err = client.ListSecrets("dead-c0de", "kv/jenkins", true)
+	assert.Nil(t, err, "Expected ListSecrets() to return nil for KV v1: %v", err)
+	assert.Equal(t, []string{"admin", "dev/admin"}, keys)
+
+	_, err = client.ListSecrets("dead-c0de", "secret/missing", false)
+	assert.NotNil(t, err, "Expected ListSecrets() to return error for a missing path")
+}
@@ -0,0 +1,349 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	path "path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+var (
+	// The mode of the socket when v.SocketMode is not set, only the user running vault-helper can connect
+	DefaultSocketMode = "0600"
+
+	// How long Serve waits for requests in flight to finish once it is stopped
+	ServeShutdownTimeout = 5 * time.Second
+)
+
+// The secrets served by Serve, with when to fetch them again
+type cachedSecret struct {
+	secret  *Secret
+	expires time.Time
+}
+
+type secretServer struct {
+	v        *Client
+	cacheTTL time.Duration
+
+	// Guards the Client, which is not safe for concurrent use, along with the cache
+	mutex sync.Mutex
+	cache map[string]*cachedSecret
+}
+
+// Logs in once and answers 'GET /secret/<path>?selector=((.key))' on a unix socket until ctx is done, so every process
+// on a host can share one token instead of logging in on its own. Without a selector, all keys of the secret are
+// returned as JSON, and '&version=<n>' fetches a specific KV v2 version. Secrets are cached for cacheTTL, or until
+// their lease is about to expire when that is sooner. The token is kept alive like WatchFile does, and revoked along
+// with the leases of dynamic secrets once ctx is done. Access is controlled by the mode and owner of the socket
+// (v.SocketMode and v.SocketOwner).
+func (v *Client) Serve(ctx context.Context, roleId, secretId, socket string, cacheTTL time.Duration) error {
+	v.RoleId = roleId
+	v.SecretId = secretId
+
+	err := v.ValidateServe(socket, cacheTTL)
+	if err != nil {
+		return &ValidationError{Err: err}
+	}
+
+	// Create the token
+	err = v.login()
+	if err != nil {
+		return err
+	}
+
+	listener, err := v.listenSocket(socket)
+	if err != nil {
+		v.revokeToken()
+		return err
+	}
+
+	s := &secretServer{v: v, cacheTTL: cacheTTL, cache: make(map[string]*cachedSecret)}
+	server := &http.Server{Handler: s}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	logger.Infof("Serving secrets on %v", socket)
+
+	tokenTimer := time.NewTimer(v.tokenRenewDelay())
+	defer tokenTimer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), ServeShutdownTimeout)
+			err = server.Shutdown(shutdownCtx)
+			cancel()
+			if err != nil {
+				logger.Warnf("Could not wait for requests on %v to finish: %v", socket, err)
+			}
+
+			s.mutex.Lock()
+			v.revokeLeases()
+			v.revokeToken()
+			s.mutex.Unlock()
+
+			logger.Infof("Stopped serving secrets on %v and revoked token", socket)
+			return nil
+
+		case err = <-served:
+			s.mutex.Lock()
+			v.revokeLeases()
+			v.revokeToken()
+			s.mutex.Unlock()
+
+			return fmt.Errorf("Could not serve secrets on %v: %w", socket, err)
+
+		case <-tokenTimer.C:
+			s.mutex.Lock()
+			err = v.keepTokenAlive(s.dropLeased)
+			s.mutex.Unlock()
+
+			if err != nil {
+				logger.Errorf("Could not renew or create token, retrying in %v: %v", WatchRetryInterval, err)
+				tokenTimer.Reset(WatchRetryInterval)
+				continue
+			}
+
+			tokenTimer.Reset(v.tokenRenewDelay())
+		}
+	}
+}
+
+func (v *Client) ValidateServe(socket string, cacheTTL time.Duration) error {
+	// Make sure we have what we need to log in
+	err := v.ValidateLogin()
+	if err != nil {
+		return err
+	}
+
+	// Make sure socket is non-empty
+	if socket == "" {
+		return errors.New("Socket cannot be empty")
+	}
+
+	// Make sure the cache TTL is positive
+	if cacheTTL <= 0 {
+		return errors.New("Cache TTL must be greater than zero")
+	}
+
+	// Make sure socket mode is a valid octal file mode
+	if v.SocketMode != "" {
+		if _, err := parseFileMode(v.SocketMode); err != nil {
+			return err
+		}
+	}
+
+	// Make sure socket owner is a known user (and group)
+	if v.SocketOwner != "" {
+		if _, _, err := lookupOwner(v.SocketOwner); err != nil {
+			return err
+		}
+	}
+
+	// Make sure nobody else can replace the socket with their own
+	err = checkSocketDir(path.Dir(socket))
+	if err != nil {
+		return err
+	}
+
+	// Make sure we do not remove anything but a stale socket
+	if info, err := os.Lstat(socket); err == nil && info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("The socket %v already exists and is not a socket", socket)
+	}
+
+	return nil
+}
+
+// The directory of the socket must exist, and only we may be able to replace the socket in it. Otherwise, anyone could
+// remove our socket and listen in our place.
+func checkSocketDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("The socket directory %v either does not exist or cannot be accessed: %v", dir, err)
+	}
+
+	if ! info.IsDir() {
+		return fmt.Errorf("The socket directory %v is not a directory", dir)
+	}
+
+	return checkPrivateDir(dir, info)
+}
+
+// Listens on the socket with v.SocketMode and v.SocketOwner, replacing a stale socket left behind by a previous run
+func (v *Client) listenSocket(socket string) (net.Listener, error) {
+	if info, err := os.Lstat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("Could not remove stale socket %v: %w", socket, err)
+		}
+	}
+
+	mode := DefaultSocketMode
+	if v.SocketMode != "" {
+		mode = v.SocketMode
+	}
+
+	perm, err := parseFileMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("Could not listen on socket %v: %w", socket, err)
+	}
+
+	if err = os.Chmod(socket, perm); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("Could not change mode of socket %v: %w", socket, err)
+	}
+
+	if v.SocketOwner != "" {
+		uid, gid, err := lookupOwner(v.SocketOwner)
+		if err == nil {
+			err = os.Chown(socket, uid, gid)
+		}
+
+		if err != nil {
+			_ = listener.Close()
+			return nil, fmt.Errorf("Could not change owner of socket %v: %w", socket, err)
+		}
+	}
+
+	return listener, nil
+}
+
+func (s *secretServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	secretPath := strings.Trim(strings.TrimPrefix(r.URL.Path, "/secret/"), "/")
+	if ! strings.HasPrefix(r.URL.Path, "/secret/") || secretPath == "" {
+		http.Error(w, "Expected a request like 'GET /secret/<path>?selector=((.key))'", http.StatusNotFound)
+		return
+	}
+
+	version := 0
+	if value := r.URL.Query().Get("version"); value != "" {
+		var err error
+
+		version, err = strconv.Atoi(value)
+		if err != nil || version < 0 {
+			s.fail(w, secretPath, &ValidationError{Err: fmt.Errorf("Could not parse version '%v'", value)})
+			return
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	secret, err := s.get(secretPath, version)
+	if err != nil {
+		s.fail(w, secretPath, err)
+		return
+	}
+
+	selector := r.URL.Query().Get("selector")
+	if selector == "" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(secret.Data)
+		return
+	}
+
+	// The 'metadata' template function reads the secret of the Client
+	s.v.Secret = *secret
+
+	var parsed bytes.Buffer
+
+	template, err := s.v.newReadOnlyTemplate("secrets").Parse(selector)
+	if err == nil {
+		err = template.Execute(&parsed, secret.Data)
+	}
+
+	if err != nil {
+		s.fail(w, secretPath, &TemplateError{Template: selector, Err: err})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(parsed.Bytes())
+}
+
+// Returns the secret from the cache, fetching it again when it expired. Dynamic secrets get their lease renewed
+// instead, so every process keeps getting the same credentials until the lease hits its max TTL.
+func (s *secretServer) get(secretPath string, version int) (*Secret, error) {
+	key := fmt.Sprintf("%v?version=%v", secretPath, version)
+
+	if cached, ok := s.cache[key]; ok {
+		if time.Now().Before(cached.expires) {
+			return cached.secret, nil
+		}
+
+		if s.v.renewLease(cached.secret) {
+			cached.expires = time.Now().Add(secretRefreshDelay(cached.secret, s.cacheTTL))
+			return cached.secret, nil
+		}
+	}
+
+	secret, err := new(Secret).GetPath(s.v, secretPath, version)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache[key] = &cachedSecret{secret: secret, expires: time.Now().Add(secretRefreshDelay(secret, s.cacheTTL))}
+	logger.Debugf("Fetched secret %v, cached for %v", secretPath, secretRefreshDelay(secret, s.cacheTTL))
+
+	return secret, nil
+}
+
+// Drops the dynamic secrets from the cache, and those of the 'secret' template function, once the token they were
+// fetched with is about to be revoked, so they are fetched again with the new token the next time they are asked for
+func (s *secretServer) dropLeased() error {
+	s.v.leasedSecrets = nil
+
+	for key, cached := range s.cache {
+		if cached.secret.LeaseId != "" {
+			delete(s.cache, key)
+		}
+	}
+
+	return nil
+}
+
+// Replies with the error, and a status code telling apart bad requests from vault refusing or failing them
+func (s *secretServer) fail(w http.ResponseWriter, secretPath string, err error) {
+	var (
+		validationError *ValidationError
+		templateError   *TemplateError
+		responseError   *ResponseError
+		httpError       *HTTPError
+	)
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &validationError), errors.As(err, &templateError):
+		status = http.StatusBadRequest
+	case errors.As(err, &responseError):
+		status = responseError.StatusCode
+	case errors.As(err, &httpError):
+		status = http.StatusBadGateway
+	}
+
+	logger.Warnf("Could not serve secret %v: %v", secretPath, err)
+	http.Error(w, logger.RedactString(err.Error()), status)
+}
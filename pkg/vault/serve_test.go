@@ -0,0 +1,152 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	path "path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+// An HTTP client talking to the unix socket, whatever the host of the URL
+func newSocketClient(socket string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+// Returns the status code and body of a request to the socket
+func getSocket(t *testing.T, client *http.Client, method, url string) (int, string) {
+	request, err := http.NewRequest(method, "http://vault-helper"+url, nil)
+	assert.Nil(t, err)
+
+	response, err := client.Do(request)
+	if ! assert.Nil(t, err, "Expected request to %v to succeed: %v", url, err) {
+		return 0, ""
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	assert.Nil(t, err)
+
+	return response.StatusCode, string(body)
+}
+
+func TestClient_ValidateServe(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	dir := t.TempDir()
+	socket := path.Join(dir, "vault-helper.sock")
+
+	// Invalid cache TTL
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "", "", "")
+	assert.NotNil(t, client.ValidateServe(socket, 0), "Expected ValidateServe() to return error for zero cache TTL")
+
+	// Missing socket directory
+	assert.NotNil(t, client.ValidateServe(path.Join(dir, "foo", "vault-helper.sock"), time.Minute), "Expected ValidateServe() to return error for missing socket directory")
+
+	// Socket directory writable by other users
+	public := path.Join(dir, "public")
+	assert.Nil(t, os.Mkdir(public, 0755))
+	assert.Nil(t, os.Chmod(public, 0777))
+	assert.NotNil(t, client.ValidateServe(path.Join(public, "vault-helper.sock"), time.Minute), "Expected ValidateServe() to return error for world-writable socket directory")
+
+	// Existing file that is not a socket
+	assert.Nil(t, os.WriteFile(socket, []byte{}, 0600))
+	assert.NotNil(t, client.ValidateServe(socket, time.Minute), "Expected ValidateServe() to return error for existing file")
+	assert.Nil(t, os.Remove(socket))
+
+	// Invalid socket mode
+	client.SocketMode = "0999"
+	assert.NotNil(t, client.ValidateServe(socket, time.Minute), "Expected ValidateServe() to return error for invalid socket mode")
+
+	// Valid socket
+	client.SocketMode = "0660"
+	assert.Nil(t, client.ValidateServe(socket, time.Minute), "Expected ValidateServe() to return nil for valid socket: %v", client.ValidateServe(socket, time.Minute))
+}
+
+func TestClient_Serve(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"secret/jenkins/admin": {"username": "kevin", "password": "bacon"},
+	})
+
+	socket := path.Join(t.TempDir(), "vault-helper.sock")
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.Serve(ctx, "dead-beef", "ea7-beef", socket, time.Minute)
+	}()
+
+	// Wait for the socket to show up
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(socket)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "Expected Serve() to create the socket")
+
+	info, err := os.Stat(socket)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Expected the socket to only be accessible by its owner")
+
+	socketClient := newSocketClient(socket)
+
+	// A selector is rendered like 'secret' does
+	status, body := getSocket(t, socketClient, http.MethodGet, "/secret/secret/jenkins/admin?selector=((.username))")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "kevin", body)
+
+	// Without a selector, every key is returned as JSON, from the cache
+	status, body = getSocket(t, socketClient, http.MethodGet, "/secret/secret/jenkins/admin")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"username": "kevin", "password": "bacon"}`, body)
+	assert.Equal(t, 1, server.count("GET /v1/secret/data/jenkins/admin"), "Expected the secret to be fetched once")
+	assert.Equal(t, 1, server.count("POST /v1/auth/approle/login"), "Expected to log in once")
+
+	// Errors are told apart by their status
+	status, _ = getSocket(t, socketClient, http.MethodGet, "/secret/secret/jenkins/missing?selector=((.username))")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = getSocket(t, socketClient, http.MethodGet, "/secret/secret/jenkins/admin?selector=((.username")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Selectors only read secrets, they cannot issue certificates or use a transit key with the token
+	status, _ = getSocket(t, socketClient, http.MethodGet, `/secret/secret/jenkins/admin?selector=((pkiCert "pki/issue/web" "cn=evil.example.com"))`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 0, server.count("POST /v1/pki/issue/web"), "Expected no certificate to be issued")
+
+	status, _ = getSocket(t, socketClient, http.MethodGet, `/secret/secret/jenkins/admin?selector=((transitDecrypt "transit/decrypt/app" "vault:v1:YmFjb24="))`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 0, server.count("POST /v1/transit/decrypt/app"), "Expected nothing to be decrypted")
+
+	status, _ = getSocket(t, socketClient, http.MethodPost, "/secret/secret/jenkins/admin")
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+
+	status, _ = getSocket(t, socketClient, http.MethodGet, "/foo")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Stopping revokes the token and removes the socket
+	cancel()
+	select {
+	case err = <-done:
+		assert.Nil(t, err, "Expected Serve() to return nil once stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Serve() to stop once ctx is done")
+	}
+
+	assert.Equal(t, 1, server.count("POST /v1/auth/token/revoke-self"), "Expected the token to be revoked")
+	_, err = os.Stat(socket)
+	assert.True(t, errors.Is(err, os.ErrNotExist), "Expected the socket to be removed, got: %v", err)
+}
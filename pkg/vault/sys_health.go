@@ -14,12 +14,15 @@ type SystemHealth struct {
 	Standby     bool `json:"standby"`
 }
 
-func (i *SystemHealth) Reload(v *Client) *SystemHealth {
-	response, err := v.client.NewRequest().SetContext(v.ctx).SetResult(i).SetError(VaultClientErrors{}).Get(SysHealthLocation)
+func (i *SystemHealth) Reload(v *Client) (*SystemHealth, error) {
+	response, err := v.newRequest("").SetResult(i).SetError(VaultClientErrors{}).Get(SysHealthLocation)
 
-	v.checkResponseForErrors(response, err, http.StatusOK)
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
 
-	return i
+	return i, nil
 }
 
 func (i *SystemHealth) Ready() bool {
@@ -0,0 +1,41 @@
+package vault
+
+import (
+	"net/http"
+)
+
+var (
+	SysLeasesRenewLocation  = "/sys/leases/renew"
+	SysLeasesRevokeLocation = "/sys/leases/revoke"
+)
+
+type LeaseInput struct {
+	LeaseId   string `json:"lease_id"`
+	Increment int    `json:"increment,omitempty"`
+}
+
+type Lease struct {
+	LeaseId       string `json:"lease_id"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// Renews the lease of a dynamic secret, like database credentials, asking for another increment seconds. Vault caps the
+// renewed lease duration at the max TTL of the lease.
+func (i *Lease) Renew(v *Client, leaseId string, increment int) (*Lease, error) {
+	response, err := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetBody(&LeaseInput{LeaseId: leaseId, Increment: increment}).SetResult(i).SetError(VaultClientErrors{}).Put(SysLeasesRenewLocation)
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// Revokes the lease of a dynamic secret, which invalidates the credentials right away
+func (i *Lease) Revoke(v *Client, leaseId string) error {
+	response, err := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetBody(&LeaseInput{LeaseId: leaseId}).SetError(VaultClientErrors{}).Put(SysLeasesRevokeLocation)
+
+	return v.checkResponseForErrors(response, err, http.StatusNoContent)
+}
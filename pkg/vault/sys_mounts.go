@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	SysInternalUIMountsLocation = "/sys/internal/ui/mounts"
+)
+
+type SystemMount struct {
+	Data SystemMountData `json:"data"`
+}
+
+type SystemMountData struct {
+	Path    string            `json:"path"`
+	Type    string            `json:"type"`
+	Options map[string]string `json:"options"`
+}
+
+// Looks up the mount the given secret path lives on. Vault allows this for any token with a capability on the path.
+func (i *SystemMount) Reload(v *Client, secretPath string) (*SystemMount, error) {
+	response, err := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetResult(i).SetError(VaultClientErrors{}).Get(fmt.Sprintf("%v/%v", SysInternalUIMountsLocation, strings.TrimPrefix(secretPath, "/")))
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// Returns the KV secrets engine version of the mount, or 0 if it is not a KV mount at all
+func (i *SystemMount) KVVersion() int {
+	switch i.Data.Type {
+	case "kv", "generic":
+		if i.Data.Options["version"] == "2" {
+			return 2
+		}
+
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Rewrites a logical KV v2 path like 'secret/foo' to its API path under segment, like 'secret/data/foo'. Paths that
+// already point under segment are left alone, so existing invocations using 'secret/data/foo' keep working.
+func (i *SystemMount) APIPath(secretPath, segment string) string {
+	secretPath = strings.TrimPrefix(secretPath, "/")
+	mountPath := strings.TrimSuffix(strings.TrimPrefix(i.Data.Path, "/"), "/") + "/"
+
+	if ! strings.HasPrefix(secretPath, mountPath) {
+		return secretPath
+	}
+
+	relative := strings.TrimPrefix(secretPath, mountPath)
+	if relative == segment || strings.HasPrefix(relative, segment+"/") {
+		return secretPath
+	}
+
+	return mountPath + segment + "/" + relative
+}
@@ -0,0 +1,40 @@
+package vault_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestSystemMount_KVVersion(t *testing.T) {
+	mount := &vault.SystemMount{Data: vault.SystemMountData{Path: "secret/", Type: "kv", Options: map[string]string{"version": "2"}}}
+	assert.Equal(t, 2, mount.KVVersion(), "Expected KVVersion() to return 2 for a kv mount with version 2")
+
+	mount = &vault.SystemMount{Data: vault.SystemMountData{Path: "kv/", Type: "kv"}}
+	assert.Equal(t, 1, mount.KVVersion(), "Expected KVVersion() to return 1 for a kv mount without options")
+
+	mount = &vault.SystemMount{Data: vault.SystemMountData{Path: "database/", Type: "database"}}
+	assert.Equal(t, 0, mount.KVVersion(), "Expected KVVersion() to return 0 for a database mount")
+
+	mount = &vault.SystemMount{}
+	assert.Equal(t, 0, mount.KVVersion(), "Expected KVVersion() to return 0 for an unknown mount")
+}
+
+func TestSystemMount_APIPath(t *testing.T) {
+	mount := &vault.SystemMount{Data: vault.SystemMountData{Path: "secret/", Type: "kv", Options: map[string]string{"version": "2"}}}
+
+	tests := map[string]string{
+		"secret/jenkins/dev/user/admin":      "secret/data/jenkins/dev/user/admin",
+		"/secret/jenkins/dev/user/admin":     "secret/data/jenkins/dev/user/admin",
+		"secret/data/jenkins/dev/user/admin": "secret/data/jenkins/dev/user/admin",
+		"secret/database/credentials":        "secret/data/database/credentials",
+		"other/jenkins/dev/user/admin":       "other/jenkins/dev/user/admin",
+	}
+
+	for secretPath, expected := range tests {
+		assert.Equal(t, expected, mount.APIPath(secretPath, "data"), "Expected APIPath() to rewrite '%v' to '%v'", secretPath, expected)
+	}
+
+	assert.Equal(t, "secret/metadata/jenkins", mount.APIPath("secret/jenkins", "metadata"), "Expected APIPath() to rewrite 'secret/jenkins' under metadata")
+}
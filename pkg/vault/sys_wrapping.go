@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"net/http"
+)
+
+var (
+	SysWrappingLookupLocation = "/sys/wrapping/lookup"
+	SysWrappingUnwrapLocation = "/sys/wrapping/unwrap"
+)
+
+type WrappingLookupInput struct {
+	Token string `json:"token"`
+}
+
+type SystemWrapping struct {
+	Data SystemWrappingData `json:"data"`
+}
+
+type SystemWrappingData struct {
+	CreationPath string `json:"creation_path"`
+	CreationTime string `json:"creation_time"`
+	CreationTTL  int    `json:"creation_ttl"`
+}
+
+// Looks up where the wrapping token was created, without unwrapping it. Vault allows this without a token.
+func (i *SystemWrapping) Lookup(v *Client, wrappingToken string) (*SystemWrapping, error) {
+	response, err := v.newRequest(v.authNamespace()).SetBody(&WrappingLookupInput{Token: wrappingToken}).SetResult(i).SetError(VaultClientErrors{}).Post(SysWrappingLookupLocation)
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+type SystemUnwrap struct {
+	*Response
+}
+
+// Unwraps the response wrapped by the wrapping token. A wrapping token can only be unwrapped once.
+func (i *SystemUnwrap) Unwrap(v *Client, wrappingToken string) (*SystemUnwrap, error) {
+	response, err := v.newRequest(v.authNamespace()).SetHeader("X-Vault-Token", wrappingToken).SetResult(i).SetError(VaultClientErrors{}).Post(SysWrappingUnwrapLocation)
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
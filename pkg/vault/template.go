@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"fmt"
+	"text/template"
+)
+
+// Creates a new, empty template using our delimiters and the functions available to both selectors and files. Each
+// template gets its own cache for the 'secret' function, so every path is fetched at most once per render. Dynamic
+// secrets are kept across renders as well, see getSecretCached.
+func (v *Client) newTemplate(name string) *template.Template {
+	return template.New(name).Delims(LeftTemplateDelim, RightTemplateDelim).Funcs(v.templateFuncs(make(map[string]*Secret)))
+}
+
+// Same as newTemplate, without the functions that issue certificates or use a transit key, for selectors that come from
+// whoever can connect to the socket of 'serve' rather than from the operator
+func (v *Client) newReadOnlyTemplate(name string) *template.Template {
+	funcs := v.templateFuncs(make(map[string]*Secret))
+	for _, name := range []string{"pkiCert", "transitDecrypt", "transitEncrypt"} {
+		delete(funcs, name)
+	}
+
+	return template.New(name).Delims(LeftTemplateDelim, RightTemplateDelim).Funcs(funcs)
+}
+
+func (v *Client) templateFuncs(secrets map[string]*Secret) template.FuncMap {
+	return template.FuncMap{
+		// The KV v2 metadata of the secret at v.Path, like '((metadata.version))' or '((metadata.created_time))'
+		"metadata": func() map[string]interface{} {
+			return v.Secret.Metadata
+		},
+
+		// A key from the secret at any path, fetched with the current token, like '((secret "secret/db" "password"))'
+		"secret": func(secretPath, key string) (interface{}, error) {
+			secret, ok := secrets[secretPath]
+			if ! ok {
+				var err error
+
+				secret, err = v.getSecretCached(secretPath)
+				if err != nil {
+					return nil, err
+				}
+
+				secrets[secretPath] = secret
+			}
+
+			value, ok := secret.Data[key]
+			if ! ok {
+				return nil, fmt.Errorf("Key '%v' does not exist in secret %v", key, secretPath)
+			}
+
+			return value, nil
+		},
+
+		// A new certificate from a PKI role, with its private key and CA chain, like
+		// '((with pkiCert "pki/issue/web" "cn=host.example.com"))((.certificate))((end))'
+		"pkiCert": func(issuePath string, parameters ...string) (map[string]interface{}, error) {
+			parsed, err := parsePKIParameters(parameters)
+			if err != nil {
+				return nil, err
+			}
+
+			return v.issueCachedCertificate(issuePath, parsed)
+		},
+
+		// The plaintext of a transit ciphertext, like '((transitDecrypt "transit/decrypt/app" "vault:v1:..."))'
+		"transitDecrypt": func(decryptPath, ciphertext string) (string, error) {
+			plaintext, err := new(Transit).Decrypt(v, decryptPath, ciphertext)
+			if err != nil {
+				return "", err
+			}
+
+			return string(plaintext), nil
+		},
+
+		// The transit ciphertext of a plaintext, like '((transitEncrypt "transit/encrypt/app" .password))'
+		"transitEncrypt": func(encryptPath, plaintext string) (string, error) {
+			return v.encryptCached(encryptPath, []byte(plaintext))
+		},
+	}
+}
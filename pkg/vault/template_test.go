@@ -0,0 +1,38 @@
+package vault_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_FetchSecretTemplateFuncs(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"secret/jenkins/admin": {"username": "kevin"},
+		"kv/db":                {"password": "bacon"},
+		"kv/api":               {"key": "c0ffee"},
+	})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// Secrets from KV v2 are unwrapped, with the metadata available separately, and other paths can be fetched inline
+	parsed, err := client.FetchSecret("dead-c0de", "secret/jenkins/admin", `((.username)) ((secret "kv/db" "password")) ((secret "kv/api" "key")) ((secret "kv/db" "password")) ((metadata.version))`)
+	assert.Nil(t, err, "Expected FetchSecret() to return nil: %v", err)
+	assert.Equal(t, "kevin bacon c0ffee bacon 1", parsed)
+
+	// Each path is only fetched once per render
+	assert.Equal(t, 1, server.count("GET /v1/kv/db"), "Expected secret 'kv/db' to be fetched once")
+
+	// Missing keys are an error rather than rendering '<no value>'
+	_, err = client.FetchSecret("dead-c0de", "secret/jenkins/admin", `((secret "kv/db" "username"))`)
+	assert.NotNil(t, err, "Expected FetchSecret() to return error for missing key 'username' in 'kv/db'")
+
+	// A specific version of a KV v2 secret can be requested
+	client.Version = 3
+	parsed, err = client.FetchSecret("dead-c0de", "secret/jenkins/admin", `((.username)) ((metadata.version))`)
+	assert.Nil(t, err, "Expected FetchSecret() to return nil for version 3: %v", err)
+	assert.Equal(t, "kevin 3", parsed)
+}
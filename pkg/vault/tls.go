@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	path "path/filepath"
+)
+
+// The TLS settings of the transport used to talk to vault, matching the VAULT_CACERT, VAULT_CAPATH, VAULT_CLIENT_CERT,
+// VAULT_CLIENT_KEY, VAULT_TLS_SERVER_NAME and VAULT_SKIP_VERIFY settings of the vault CLI.
+type TLSConfig struct {
+	// A PEM file of CA certificates to verify the vault server with, instead of the system roots
+	CACert string
+
+	// A directory of PEM files of CA certificates to verify the vault server with, instead of the system roots
+	CAPath string
+
+	// A PEM client certificate and key, presented to vault for mutual TLS and the cert auth method
+	ClientCert string
+	ClientKey  string
+
+	// The server name to verify the vault certificate against, when it differs from the host in the address
+	ServerName string
+
+	// Skip verifying the vault server certificate altogether
+	Insecure bool
+}
+
+// Builds the crypto/tls config, loading the CA and client certificates from disk
+func (i *TLSConfig) Build() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: i.Insecure,
+		ServerName:         i.ServerName,
+	}
+
+	if i.CACert != "" || i.CAPath != "" {
+		pool := x509.NewCertPool()
+
+		if i.CACert != "" {
+			err := appendCertificates(pool, i.CACert)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if i.CAPath != "" {
+			files, err := os.ReadDir(i.CAPath)
+			if err != nil {
+				return nil, fmt.Errorf("Could not read CA path '%v': %v", i.CAPath, err)
+			}
+
+			for _, file := range files {
+				if file.IsDir() {
+					continue
+				}
+
+				err = appendCertificates(pool, path.Join(i.CAPath, file.Name()))
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		config.RootCAs = pool
+	}
+
+	if i.ClientCert != "" || i.ClientKey != "" {
+		if i.ClientCert == "" || i.ClientKey == "" {
+			return nil, fmt.Errorf("Client certificate and client key must be given together")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(i.ClientCert, i.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load client certificate '%v' and key '%v': %v", i.ClientCert, i.ClientKey, err)
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+func appendCertificates(pool *x509.CertPool, file string) error {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("Could not read CA certificate '%v': %v", file, err)
+	}
+
+	if ! pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("Could not find any PEM certificates in CA certificate '%v'", file)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_ValidateTLS(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Missing CA certificate
+	client = &vault.Client{Address: "https://google.com", TLS: vault.TLSConfig{CACert: "foobar.pem"}}
+	assert.NotNil(t, client.Validate(), "Expected Validate() to return error for missing CA certificate 'foobar.pem'")
+
+	// CA certificate without any certificates in it
+	client = &vault.Client{Address: "https://google.com", TLS: vault.TLSConfig{CACert: "example.groovy"}}
+	assert.NotNil(t, client.Validate(), "Expected Validate() to return error for CA certificate without certificates")
+
+	// Missing CA path
+	client = &vault.Client{Address: "https://google.com", TLS: vault.TLSConfig{CAPath: "foobar"}}
+	assert.NotNil(t, client.Validate(), "Expected Validate() to return error for missing CA path 'foobar'")
+
+	// Client certificate without a key
+	client = &vault.Client{Address: "https://google.com", TLS: vault.TLSConfig{ClientCert: "example.groovy"}}
+	assert.NotNil(t, client.Validate(), "Expected Validate() to return error for client certificate without a key")
+}
+
+func TestClient_TLS(t *testing.T) {
+	server := newFakeVaultTLS(t, map[string]map[string]interface{}{})
+
+	// Write the self-signed certificate of the server out as our CA, both as a file and in a directory
+	dir := t.TempDir()
+	caCert := path.Join(dir, "ca.pem")
+	err := os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+	assert.Nil(t, err)
+
+	// The server cannot be verified with the system roots
+	_, err = vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.NotNil(t, err, "Expected NewVaultClient() to return error for self-signed certificate")
+
+	// The server is verified with the CA certificate or CA path
+	_, err = vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{CACert: caCert})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil with CA certificate: %v", err)
+
+	_, err = vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{CAPath: dir})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil with CA path: %v", err)
+
+	// The certificate is for 'example.com', so any other server name fails verification
+	_, err = vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{CACert: caCert, ServerName: "vault.example.org"})
+	assert.NotNil(t, err, "Expected NewVaultClient() to return error for server name 'vault.example.org'")
+
+	_, err = vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{CACert: caCert, ServerName: "example.com"})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for server name 'example.com': %v", err)
+
+	// Skipping verification works without a CA
+	_, err = vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{Insecure: true})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil when skipping verification: %v", err)
+}
@@ -0,0 +1,142 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+type TransitEncryptInput struct {
+	Plaintext string `json:"plaintext"`
+}
+
+type TransitDecryptInput struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+type Transit struct {
+	Data TransitData `json:"data"`
+}
+
+type TransitData struct {
+	Ciphertext string `json:"ciphertext"`
+	Plaintext  string `json:"plaintext"`
+}
+
+// Encrypts plaintext with a transit key, like 'transit/encrypt/app', returning a ciphertext like 'vault:v1:...'. The
+// plaintext is base64 encoded for vault, so it can be binary.
+func (i *Transit) Encrypt(v *Client, encryptPath string, plaintext []byte) (string, error) {
+	response, err := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetBody(&TransitEncryptInput{Plaintext: base64.StdEncoding.EncodeToString(plaintext)}).SetResult(i).SetError(VaultClientErrors{}).Post(strings.TrimPrefix(encryptPath, "/"))
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+
+	return i.Data.Ciphertext, nil
+}
+
+// Decrypts a ciphertext like 'vault:v1:...' with a transit key, like 'transit/decrypt/app', returning the plaintext
+// decoded from the base64 vault returns it in
+func (i *Transit) Decrypt(v *Client, decryptPath string, ciphertext string) ([]byte, error) {
+	response, err := v.newRequest(v.Namespace).SetHeader("X-Vault-Token", v.Token).SetBody(&TransitDecryptInput{Ciphertext: strings.TrimSpace(ciphertext)}).SetResult(i).SetError(VaultClientErrors{}).Post(strings.TrimPrefix(decryptPath, "/"))
+
+	err = v.checkResponseForErrors(response, err, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(i.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decode the plaintext vault returned from %v: %w", decryptPath, err)
+	}
+
+	redactValues(string(plaintext))
+
+	return plaintext, nil
+}
+
+// Given the token, encrypts plaintext with the transit key at mount, returning the ciphertext
+func (v *Client) TransitEncrypt(token, mount, key string, plaintext []byte) (string, error) {
+	v.Token = token
+	logger.Redact(v.Token)
+
+	err := v.ValidateTransit(mount, key)
+	if err != nil {
+		return "", &ValidationError{Err: err}
+	}
+
+	return new(Transit).Encrypt(v, transitPath(mount, "encrypt", key), plaintext)
+}
+
+// Given the token, decrypts ciphertext with the transit key at mount, returning the plaintext
+func (v *Client) TransitDecrypt(token, mount, key, ciphertext string) ([]byte, error) {
+	v.Token = token
+	logger.Redact(v.Token)
+
+	err := v.ValidateTransit(mount, key)
+	if err != nil {
+		return nil, &ValidationError{Err: err}
+	}
+
+	// Make sure ciphertext is non-empty
+	if strings.TrimSpace(ciphertext) == "" {
+		return nil, &ValidationError{Err: errors.New("Ciphertext cannot be empty")}
+	}
+
+	return new(Transit).Decrypt(v, transitPath(mount, "decrypt", key), ciphertext)
+}
+
+func (v *Client) ValidateTransit(mount, key string) error {
+	// Make sure token is non-empty
+	if v.Token == "" {
+		return errors.New("Token cannot be empty")
+	}
+
+	// Make sure mount is non-empty
+	if strings.Trim(mount, "/") == "" {
+		return errors.New("Mount cannot be empty")
+	}
+
+	// Make sure key is non-empty
+	if key == "" {
+		return errors.New("Key cannot be empty")
+	}
+
+	return nil
+}
+
+// Encrypts plaintext for the transitEncrypt template function. Ciphertexts are kept for the lifetime of the Client,
+// since every encryption comes out different, and re-rendering a file with 'parse --watch' would otherwise rewrite it
+// (and run --exec-on-change) every interval.
+func (v *Client) encryptCached(encryptPath string, plaintext []byte) (string, error) {
+	hash := sha256.Sum256(plaintext)
+	key := encryptPath + "?" + hex.EncodeToString(hash[:])
+
+	if ciphertext, ok := v.ciphertexts[key]; ok {
+		return ciphertext, nil
+	}
+
+	ciphertext, err := new(Transit).Encrypt(v, encryptPath, plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	if v.ciphertexts == nil {
+		v.ciphertexts = make(map[string]string)
+	}
+	v.ciphertexts[key] = ciphertext
+
+	return ciphertext, nil
+}
+
+// The path of a transit operation for a key, like 'transit/encrypt/app'
+func transitPath(mount, operation, key string) string {
+	return fmt.Sprintf("%v/%v/%v", strings.Trim(mount, "/"), operation, key)
+}
@@ -0,0 +1,120 @@
+package vault_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_ValidateTransit(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Missing token
+	client = Setup("https://google.com", "", "", "", "", "", "")
+	assert.NotNil(t, client.ValidateTransit("transit", "app"), "Expected ValidateTransit() to return error for empty token")
+
+	// Missing mount
+	client = Setup("https://google.com", "", "", "dead-c0de", "", "", "")
+	assert.NotNil(t, client.ValidateTransit("/", "app"), "Expected ValidateTransit() to return error for empty mount")
+
+	// Missing key
+	assert.NotNil(t, client.ValidateTransit("transit", ""), "Expected ValidateTransit() to return error for empty key")
+
+	// Valid mount and key
+	assert.Nil(t, client.ValidateTransit("transit", "app"), "Expected ValidateTransit() to return nil for valid mount and key")
+}
+
+func TestClient_Transit(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{})
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+
+	// Binary plaintext makes it through the base64 vault uses both ways
+	plaintext := []byte{0x00, 0xff, 'b', 'a', 'c', 'o', 'n', '\n'}
+
+	ciphertext, err := client.TransitEncrypt("dead-c0de", "transit", "app", plaintext)
+	assert.Nil(t, err, "Expected TransitEncrypt() to return nil: %v", err)
+	assert.Equal(t, "vault:v1:AP9iYWNvbgo=", ciphertext)
+	assert.Equal(t, 1, server.count("POST /v1/transit/encrypt/app"))
+
+	decrypted, err := client.TransitDecrypt("dead-c0de", "transit/", "app", ciphertext+"\n")
+	assert.Nil(t, err, "Expected TransitDecrypt() to return nil: %v", err)
+	assert.Equal(t, plaintext, decrypted)
+
+	_, err = client.TransitDecrypt("dead-c0de", "transit", "app", "")
+	assert.NotNil(t, err, "Expected TransitDecrypt() to return error for empty ciphertext")
+}
+
+func TestClient_ParseFileTransitDecrypt(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"kv/app": {"user": "kevin"},
+	})
+
+	dir := t.TempDir()
+	file := path.Join(dir, "app.conf.tmpl")
+	out := path.Join(dir, "app.conf")
+
+	// 'YmFjb24=' is 'bacon'
+	err := os.WriteFile(file, []byte(`user=((.user)) password=((transitDecrypt "transit/decrypt/app" "vault:v1:YmFjb24="))`), 0644)
+	assert.Nil(t, err)
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+
+	err = client.ParseFile("dead-beef", "ea7-beef", "kv/app", file)
+	assert.Nil(t, err, "Expected ParseFile() to return nil: %v", err)
+
+	parsed, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, "user=kevin password=bacon", string(parsed))
+
+	// A ciphertext vault cannot decrypt fails the render
+	err = os.WriteFile(file, []byte(`((transitDecrypt "transit/decrypt/app" "YmFjb24="))`), 0644)
+	assert.Nil(t, err)
+
+	err = client.ParseFile("dead-beef", "ea7-beef", "kv/app", file)
+	assert.NotNil(t, err, "Expected ParseFile() to return error for invalid ciphertext")
+}
+
+func TestClient_WatchFileTransitEncrypt(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"kv/app": {"password": "bacon"},
+	})
+
+	dir := t.TempDir()
+	file := path.Join(dir, "app.conf.tmpl")
+	out := path.Join(dir, "app.conf")
+	assert.Nil(t, os.WriteFile(file, []byte(`password=((transitEncrypt "transit/encrypt/app" .password))`), 0644))
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.WatchFile(ctx, "dead-beef", "ea7-beef", "kv/app", file, 10*time.Millisecond)
+	}()
+
+	// Re-renders encrypt the same plaintext once, rather than coming out different every interval
+	assert.Eventually(t, func() bool {
+		return server.count("GET /v1/kv/app") >= 3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done, "Expected WatchFile() to return nil once stopped")
+
+	assert.Equal(t, 1, server.count("POST /v1/transit/encrypt/app"), "Expected the plaintext to be encrypted once")
+
+	parsed, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, "password=vault:v1:YmFjb24=", string(parsed))
+}
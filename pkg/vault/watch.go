@@ -0,0 +1,260 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"math"
+	path "path/filepath"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/logger"
+)
+
+var (
+	// The fraction of a token or secret lease after which it is renewed or fetched again
+	LeaseRenewFraction = 2.0 / 3.0
+
+	// How long to wait before retrying after vault could not be reached, or refused a login
+	WatchRetryInterval = 30 * time.Second
+)
+
+// Parses the file like ParseFile, then keeps it fresh until ctx is done. The token is renewed before it expires (or
+// replaced by logging in again once it can no longer be renewed), and the secrets are fetched again every interval, or
+// earlier when their lease is about to expire. The output file is only written when the rendered content changed. The
+// token is revoked once ctx is done.
+func (v *Client) WatchFile(ctx context.Context, roleId, secretId, vaultPath, file string, interval time.Duration) error {
+	// Set vars for parsing the file
+	v.RoleId = roleId
+	v.SecretId = secretId
+	v.Path = vaultPath
+	v.File = file
+
+	err := v.ValidateWatchFile(interval)
+	if err != nil {
+		return &ValidationError{Err: err}
+	}
+
+	// Create the token
+	err = v.login()
+	if err != nil {
+		return err
+	}
+
+	// The first render has to succeed, so a broken template or missing secret fails fast
+	_, err = v.renderFileIfChanged(true)
+	if err != nil {
+		v.revokeToken()
+		return err
+	}
+
+	logger.Infof("Successfully parsed secrets from %v to file %v, watching for changes every %v", v.Path, v.outputFile(), interval)
+
+	tokenTimer := time.NewTimer(v.tokenRenewDelay())
+	renderTimer := time.NewTimer(v.renderDelay(interval))
+	defer tokenTimer.Stop()
+	defer renderTimer.Stop()
+
+	// Set once --exec-on-change failed, so it is run again on the next render even though the file did not change again
+	commandFailed := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			v.revokeLeases()
+			v.revokeToken()
+			logger.Infof("Stopped watching %v and revoked token", v.outputFile())
+			return nil
+
+		case <-tokenTimer.C:
+			err = v.keepTokenAlive(v.refetchLeased)
+			if err != nil {
+				logger.Errorf("Could not renew or create token, retrying in %v: %v", WatchRetryInterval, err)
+				tokenTimer.Reset(WatchRetryInterval)
+				continue
+			}
+
+			tokenTimer.Reset(v.tokenRenewDelay())
+
+		case <-renderTimer.C:
+			// Dynamic secrets are renewed rather than fetched again, until their lease hits its max TTL. The file is
+			// rendered either way, for the secrets and certificates the template fetches itself.
+			changed, err := v.renderFileIfChanged(! v.renewLease(&v.Secret))
+			if changed {
+				logger.Infof("Secrets from %v changed, re-parsed file %v", v.Path, v.outputFile())
+			}
+
+			if err == nil && ! changed && commandFailed {
+				err = v.execOnChange()
+			}
+
+			var commandError *CommandError
+			commandFailed = errors.As(err, &commandError)
+
+			if commandFailed {
+				logger.Errorf("File %v was parsed, but '%v' failed, running it again in %v: %v", v.outputFile(), v.ExecOnChange, WatchRetryInterval, err)
+				renderTimer.Reset(WatchRetryInterval)
+				continue
+			}
+
+			if err != nil {
+				logger.Errorf("Could not parse secrets from %v to file %v, retrying in %v: %v", v.Path, v.outputFile(), WatchRetryInterval, err)
+				renderTimer.Reset(WatchRetryInterval)
+				continue
+			}
+
+			renderTimer.Reset(v.renderDelay(interval))
+		}
+	}
+}
+
+func (v *Client) ValidateWatchFile(interval time.Duration) error {
+	// Make sure the file can be parsed at all
+	err := v.ValidateParseFile()
+	if err != nil {
+		return err
+	}
+
+	// Make sure interval is positive
+	if interval <= 0 {
+		return errors.New("Interval must be greater than zero")
+	}
+
+	// Make sure the template survives the first render, otherwise there is nothing left to re-render
+	if v.Out == "" || path.Clean(v.Out) == path.Clean(v.File) {
+		return errors.New("Output file must be given, and differ from the template file, to watch for changes")
+	}
+
+	return nil
+}
+
+// Renders the file, only writing it (and running v.ExecOnChange) when the content differs from what is in the output
+// file. The secret at v.Path is only fetched again when fetch is set. Returns whether the output file was written.
+func (v *Client) renderFileIfChanged(fetch bool) (bool, error) {
+	var content []byte
+	var err error
+
+	if fetch {
+		content, err = v.render()
+	} else {
+		content, err = v.renderSecret(&v.Secret)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if ! v.outputChanged(content) {
+		return false, nil
+	}
+
+	err = v.writeFile(content)
+	if err != nil {
+		return false, err
+	}
+
+	return true, v.execOnChange()
+}
+
+// Renews the token if it is renewable, otherwise (or when the renewal is capped by the token's max TTL) logs in again.
+// The previous token is revoked once the new one works. That revokes the leases of the dynamic secrets fetched with it
+// as well, so when there are any, refetchLeased fetches them again with the new token first. When that fails, the
+// previous token is left to expire instead.
+func (v *Client) keepTokenAlive(refetchLeased func() error) error {
+	if v.tokenAuth != nil && v.tokenAuth.Renewable {
+		renewed, err := v.Auth.Token.RenewSelf(v)
+		if err == nil && renewed.Auth != nil && renewed.Auth.LeaseDuration >= v.tokenAuth.LeaseDuration {
+			logger.Debugf("Renewed token for another %vs", renewed.Auth.LeaseDuration)
+			v.tokenAuth.LeaseDuration = renewed.Auth.LeaseDuration
+			return nil
+		}
+
+		if err != nil {
+			logger.Warnf("Could not renew token, logging in again: %v", err)
+		} else {
+			logger.Infof("Token is close to its max TTL, logging in again")
+		}
+	}
+
+	previousToken, previousLeaseIds := v.Token, v.leaseIds
+
+	err := v.login()
+	if err != nil {
+		return err
+	}
+
+	if len(previousLeaseIds) > 0 {
+		v.leaseIds = nil
+
+		err = refetchLeased()
+		if err != nil {
+			logger.Warnf("Could not fetch dynamic secrets again with the new token, leaving the previous token to expire: %v", err)
+			return nil
+		}
+	}
+
+	currentToken := v.Token
+	v.Token = previousToken
+	v.revokeToken()
+	v.Token = currentToken
+
+	return nil
+}
+
+// Renders the file again with fresh dynamic secrets, and certificates, once the token they were fetched with is about
+// to be revoked
+func (v *Client) refetchLeased() error {
+	v.leasedSecrets = nil
+	v.certificates = nil
+
+	_, err := v.renderFileIfChanged(true)
+	return err
+}
+
+// Revokes the token on the way out, which is best effort since we are stopping (or failing) either way
+func (v *Client) revokeToken() {
+	err := v.Auth.Token.RevokeSelf(v)
+	if err != nil {
+		logger.Warnf("Could not auto-revoke token: %v", err)
+	}
+}
+
+// The time until the token should be renewed, which is never for tokens without a TTL
+func (v *Client) tokenRenewDelay() time.Duration {
+	if v.tokenAuth == nil || v.tokenAuth.LeaseDuration <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return leaseRenewDelay(v.tokenAuth.LeaseDuration)
+}
+
+// The time until the secrets should be fetched again (or their lease renewed), which is the interval unless the lease
+// of the secret, or of a dynamic secret the template fetches itself, runs out first
+func (v *Client) renderDelay(interval time.Duration) time.Duration {
+	delay := secretRefreshDelay(&v.Secret, interval)
+
+	for _, cached := range v.leasedSecrets {
+		if until := time.Until(cached.expires); until < delay {
+			delay = until
+		}
+	}
+
+	return delay
+}
+
+// The time until a secret should be fetched again, which is the interval unless its lease runs out first. Dynamic
+// secrets are not fetched every interval, since every fetch creates new credentials, but only when their lease is
+// about to run out.
+func secretRefreshDelay(secret *Secret, interval time.Duration) time.Duration {
+	if secret.LeaseId != "" && secret.LeaseDuration > 0 {
+		return leaseRenewDelay(secret.LeaseDuration)
+	}
+
+	if secret.LeaseDuration > 0 && leaseRenewDelay(secret.LeaseDuration) < interval {
+		return leaseRenewDelay(secret.LeaseDuration)
+	}
+
+	return interval
+}
+
+func leaseRenewDelay(leaseDuration int) time.Duration {
+	return time.Duration(float64(leaseDuration) * LeaseRenewFraction * float64(time.Second))
+}
@@ -0,0 +1,136 @@
+package vault_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	path "path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Indellient/vault-helper/pkg/vault"
+)
+
+func TestClient_ValidateWatchFile(t *testing.T) {
+	// Our client var
+	var client *vault.Client
+
+	// Invalid interval
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
+	assert.NotNil(t, client.ValidateWatchFile(0), "Expected ValidateWatchFile() to return error for zero interval")
+
+	// Invalid file
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "foobar.groovy", "")
+	assert.NotNil(t, client.ValidateWatchFile(time.Minute), "Expected ValidateWatchFile() to return error for invalid file 'foobar.groovy'")
+
+	// Missing output file, the first render would overwrite the template
+	client = Setup("https://google.com", "dead-beef", "ea7-beef", "", "/foo/bar", "example.groovy", "")
+	assert.NotNil(t, client.ValidateWatchFile(time.Minute), "Expected ValidateWatchFile() to return error for empty output file")
+
+	// Output file is the template file
+	client.Out = "./example.groovy"
+	assert.NotNil(t, client.ValidateWatchFile(time.Minute), "Expected ValidateWatchFile() to return error for output file equal to the template file")
+
+	// Valid interval and file
+	client.Out = "example.conf"
+	assert.Nil(t, client.ValidateWatchFile(time.Minute), "Expected ValidateWatchFile() to return nil for valid interval and file: %v", client.ValidateWatchFile(time.Minute))
+}
+
+func TestClient_WatchFile(t *testing.T) {
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"kv/db": {"password": "bacon"},
+	})
+
+	dir := t.TempDir()
+	file := path.Join(dir, "db.conf.tmpl")
+	out := path.Join(dir, "db.conf")
+
+	err := os.WriteFile(file, []byte(`password=((.password))`), 0644)
+	assert.Nil(t, err)
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.WatchFile(ctx, "dead-beef", "ea7-beef", "kv/db", file, 10*time.Millisecond)
+	}()
+
+	// The file is rendered straight away, then again once the secret is rotated
+	assert.Eventually(t, func() bool {
+		parsed, _ := os.ReadFile(out)
+		return string(parsed) == "password=bacon"
+	}, time.Second, 5*time.Millisecond)
+
+	server.set("kv/db", map[string]interface{}{"password": "eggs"})
+
+	assert.Eventually(t, func() bool {
+		parsed, _ := os.ReadFile(out)
+		return string(parsed) == "password=eggs"
+	}, time.Second, 5*time.Millisecond)
+
+	// Stopping revokes the token
+	cancel()
+	assert.Nil(t, <-done, "Expected WatchFile() to return nil once stopped")
+	assert.Equal(t, 1, server.count("POST /v1/auth/token/revoke-self"), "Expected the token to be revoked once stopped")
+}
+
+func TestClient_WatchFileExecOnChangeRetry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The command is a POSIX shell command")
+	}
+
+	server := newFakeVault(t, map[string]map[string]interface{}{
+		"kv/db": {"password": "bacon"},
+	})
+
+	defer func(interval time.Duration) { vault.WatchRetryInterval = interval }(vault.WatchRetryInterval)
+	vault.WatchRetryInterval = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	file := path.Join(dir, "db.conf.tmpl")
+	out := path.Join(dir, "db.conf")
+	fail := path.Join(dir, "fail")
+	reloads := path.Join(dir, "reloads")
+	attempts := path.Join(dir, "attempts")
+	assert.Nil(t, os.WriteFile(file, []byte(`password=((.password))`), 0644))
+
+	client, err := vault.NewVaultClient(context.Background(), server.URL, vault.TLSConfig{})
+	assert.Nil(t, err, "Expected NewVaultClient() to return nil for fake vault: %v", err)
+	client.Out = out
+	client.ExecOnChange = fmt.Sprintf("echo attempt >> %v; test ! -f %v && echo reload >> %v", attempts, fail, reloads)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- client.WatchFile(ctx, "dead-beef", "ea7-beef", "kv/db", file, 10*time.Millisecond)
+	}()
+
+	assert.Eventually(t, func() bool {
+		content, _ := os.ReadFile(reloads)
+		return string(content) == "reload\n"
+	}, time.Second, 5*time.Millisecond)
+
+	// The command fails after the secret is rotated, and is run again until it succeeds, although the file was written
+	assert.Nil(t, os.WriteFile(fail, []byte{}, 0644))
+	server.set("kv/db", map[string]interface{}{"password": "eggs"})
+
+	assert.Eventually(t, func() bool {
+		content, _ := os.ReadFile(attempts)
+		return strings.Count(string(content), "attempt") >= 3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.Nil(t, os.Remove(fail))
+	assert.Eventually(t, func() bool {
+		content, _ := os.ReadFile(reloads)
+		return string(content) == "reload\nreload\n"
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done, "Expected WatchFile() to return nil once stopped")
+}